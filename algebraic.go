@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Algebraic returns the description of a Move in standard algebraic notation.
 func Algebraic(pos Position, m Move) string {
@@ -53,6 +56,95 @@ func Algebraic(pos Position, m Move) string {
 	return s
 }
 
+// ParseAlgebraic parses s as a Move in pos in standard algebraic notation.
+// It accepts promotions with or without an equals sign (e8=Q, e8Q), castling written with
+// either letters or digits (O-O, 0-0), and trailing check, checkmate, and annotation symbols.
+// It returns an error if s is invalid or ambiguous, or if it represents an illegal move.
+func ParseAlgebraic(pos Position, s string) (Move, error) {
+	san := strings.TrimRight(s, "+#!?")
+	switch strings.ReplaceAll(san, "0", "O") {
+	case "O-O":
+		return parseCastle(pos, s, KS)
+	case "O-O-O":
+		return parseCastle(pos, s, QS)
+	}
+
+	piece := Pawn
+	if len(san) > 0 && strings.ContainsRune("NBRQK", rune(san[0])) {
+		piece = RuneToPiece[rune(san[0])]
+		san = san[1:]
+	}
+	var promote Piece
+	if n := len(san); n > 0 && strings.ContainsRune("NBRQ", rune(san[n-1])) {
+		if piece != Pawn {
+			return Move{}, fmt.Errorf("%v: promotion of non-pawn", s)
+		}
+		promote = RuneToPiece[rune(san[n-1])]
+		san = strings.TrimSuffix(san[:n-1], "=")
+	}
+	if len(san) < 2 {
+		return Move{}, fmt.Errorf("%v: missing destination square", s)
+	}
+	to, err := ParseSquare(san[len(san)-2:])
+	if err != nil {
+		return Move{}, fmt.Errorf("%v: %v", s, err)
+	}
+	san = san[:len(san)-2]
+	capture := strings.HasSuffix(san, "x")
+	san = strings.TrimSuffix(san, "x")
+
+	// The remainder disambiguates the origin square by file, rank, or both.
+	from := ^Board(0)
+	for _, r := range san {
+		switch {
+		case isFile(r):
+			from &= Files[r-'a']
+		case isNumber(r):
+			from &= Ranks[r-'1']
+		default:
+			return Move{}, fmt.Errorf("%v: invalid character %q", s, r)
+		}
+	}
+	if len(san) > 2 || (len(san) == 2 && PopCount(from) != 1) {
+		return Move{}, fmt.Errorf("%v: invalid origin", s)
+	}
+	if piece == Pawn && capture && len(san) != 1 {
+		return Move{}, fmt.Errorf("%v: pawn capture requires origin file", s)
+	}
+
+	var matches []Move
+	for _, m := range LegalMoves(pos) {
+		if m.Piece == piece && m.To == to && m.From.Board()&from != 0 && m.PromotePiece == promote {
+			matches = append(matches, m)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		if piece == Pawn && promote == None && (to.Rank() == 0 || to.Rank() == 7) {
+			return Move{}, fmt.Errorf("%v: missing promotion piece", s)
+		}
+		return Move{}, fmt.Errorf("%v: illegal move", s)
+	case 1:
+		if capture && !matches[0].IsCapture() {
+			return Move{}, fmt.Errorf("%v: not a capture", s)
+		}
+		return matches[0], nil
+	}
+	var alts []string
+	for _, m := range matches {
+		alts = append(alts, Algebraic(pos, m))
+	}
+	return Move{}, fmt.Errorf("%v: ambiguous move (%v)", s, strings.Join(alts, ", "))
+}
+
+// parseCastle returns the castling Move to side in pos, or an error if it is illegal.
+func parseCastle(pos Position, s string, side Side) (Move, error) {
+	if !canCastle(pos, side) {
+		return Move{}, fmt.Errorf("%v: illegal move", s)
+	}
+	return castleMove(pos, side), nil
+}
+
 // LongAlgebraic returns the description of a Move in long algebraic notation without check.
 func LongAlgebraic(m Move) string {
 	switch side, ok := m.IsCastle(); {
@@ -78,6 +170,17 @@ func LongAlgebraic(m Move) string {
 	return s
 }
 
+// Coordinate returns the description of a Move in pure coordinate notation,
+// the concatenation of the origin and destination Squares followed by the promoted piece
+// in the case of pawn promotion (e2e4, b1c3, e1g1, d7d8q).
+func Coordinate(m Move) string {
+	s := m.From.String() + m.To.String()
+	if m.IsPromotion() {
+		s += strings.ToLower(pieceLetter[m.PromotePiece])
+	}
+	return s
+}
+
 // moveNumber returns the number prefix of a move in algebraic notation.
 func moveNumber(pos Position) string {
 	s := fmt.Sprintf("%v.", pos.FullMove)
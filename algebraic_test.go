@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -23,6 +24,9 @@ var algebraicTests = []struct {
 	{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", Move{From: e1, To: c1, Piece: King}, "O-O-O", "O-O-O"},
 	{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", Move{From: e1, To: g1, Piece: King}, "O-O+", "O-O"},
 	{"r4k2/1P6/3P2Q1/4p3/8/8/6P1/R3K2R w KQ - 0 1", Move{From: e1, To: g1, Piece: King}, "O-O#", "O-O"},
+	{"1r2k2r/8/8/8/8/8/8/1R2K2R w HBhb - 0 1", Move{From: e1, To: b1, Piece: King, Castle960: true}, "O-O-O", "O-O-O"},
+	{"1r2k2r/8/8/8/8/8/8/1R2K2R w HBhb - 0 1", Move{From: e1, To: h1, Piece: King, Castle960: true}, "O-O", "O-O"},
+	{"6kr/8/8/8/8/8/8/6KR w Hh - 0 1", Move{From: g1, To: h1, Piece: King, Castle960: true}, "O-O", "O-O"},
 	{"7k/8/8/8/8/8/8/R4RK1 w - - 0 1", Move{From: a1, To: d1, Piece: Rook}, "Rad1", "Ra1-d1"},
 	{"7k/R7/8/8/8/8/8/R5K1 w - - 0 1", Move{From: a7, To: a6, Piece: Rook}, "R7a6", "Ra7-a6"},
 	{"7k/R7/8/8/8/8/8/R5K1 w - - 0 1", Move{From: a7, To: a8, Piece: Rook}, "Ra8+", "Ra7-a8"},
@@ -66,6 +70,103 @@ func TestAlgebraic(t *testing.T) {
 	}
 }
 
+func TestParseAlgebraic(t *testing.T) {
+	for _, test := range algebraicTests {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseAlgebraic(pos, test.alg); got != test.move || err != nil {
+			t.Errorf("ParseAlgebraic(%v, %v): got %+v, %v; want %+v, nil", test.fen, test.alg, got, err, test.move)
+		}
+	}
+	for _, test := range []struct {
+		fen  string
+		s    string
+		move Move
+	}{
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "b8=Q", Move{From: b7, To: b8, Piece: Pawn, PromotePiece: Queen}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "bxa8=N", Move{From: b7, To: a8, Piece: Pawn, CapturePiece: Rook, PromotePiece: Knight}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "0-0-0", Move{From: e1, To: c1, Piece: King}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "0-0+", Move{From: e1, To: g1, Piece: King}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "O-O!?", Move{From: e1, To: g1, Piece: King}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "Rxa8", Move{From: a1, To: a8, Piece: Rook, CapturePiece: Rook}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "Ra1a8+", Move{From: a1, To: a8, Piece: Rook, CapturePiece: Rook}},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "dxe6", Move{From: d5, To: e6, Piece: Pawn, CapturePiece: Pawn, EP: true}},
+		{"r1bqkbnr/pppppppp/2n5/8/8/5N2/PPPPPPPP/RNBQKB1R b KQkq - 2 2", "Nbd7?", Move{}},
+		{"r2qkbnr/pppnpppp/8/8/8/5N2/PPPPPPPP/RNBQKB1R b KQkq - 2 2", "Ngf6", Move{From: g8, To: f6, Piece: Knight}},
+		{"r2qkbnr/pppnpppp/8/8/8/5N2/PPPPPPPP/RNBQKB1R b KQkq - 2 2", "Ng8f6", Move{From: g8, To: f6, Piece: Knight}},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseAlgebraic(pos, test.s)
+		if test.move == (Move{}) {
+			if err == nil {
+				t.Errorf("ParseAlgebraic(%v, %v): got %+v, nil; want error", test.fen, test.s, got)
+			}
+			continue
+		}
+		if got != test.move || err != nil {
+			t.Errorf("ParseAlgebraic(%v, %v): got %+v, %v; want %+v, nil", test.fen, test.s, got, err, test.move)
+		}
+	}
+}
+
+func TestParseAlgebraicInvalid(t *testing.T) {
+	for _, test := range []struct {
+		fen string
+		s   string
+		err string
+	}{
+		{InitialPositionFEN, "", "missing destination square"},
+		{InitialPositionFEN, "N", "missing destination square"},
+		{InitialPositionFEN, "e9", "ParseSquare(e9)"},
+		{InitialPositionFEN, "e5", "illegal move"},
+		{InitialPositionFEN, "Ke2", "illegal move"},
+		{InitialPositionFEN, "O-O", "illegal move"},
+		{InitialPositionFEN, "Nf3=Q", "promotion of non-pawn"},
+		{InitialPositionFEN, "Nxf3", "not a capture"},
+		{InitialPositionFEN, "Nzf3", "invalid character"},
+		{InitialPositionFEN, "Ng1g1f3", "invalid origin"},
+		{"7k/8/8/8/8/8/R7/KR5r w - - 0 1", "Rbb2", "illegal move"}, // pinned
+		{"7k/8/8/8/8/1R6/R7/KR5r w - - 0 1", "Rb2", "ambiguous move (Rab2, Rbb2)"},
+		{"7k/4Q3/2Q5/2K2Q1r/1Q6/b3Q3/2Q5/2r5 w - - 0 1", "Qe4", "ambiguous move"},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "b8", "missing promotion piece"},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "b8=K", "ParseSquare(=K)"},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "xe6", "pawn capture requires origin file"},
+		{"r4k2/1P6/8/3Pp3/8/8/6P1/R3K2R w KQ e6 0 1", "Kxf2", "not a capture"},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, err := ParseAlgebraic(pos, test.s); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseAlgebraic(%v, %v): got %+v, %v; want error containing %q", test.fen, test.s, m, err, test.err)
+		}
+	}
+}
+
+func TestCoordinate(t *testing.T) {
+	for _, test := range []struct {
+		move Move
+		want string
+	}{
+		{Move{From: e2, To: e4, Piece: Pawn}, "e2e4"},
+		{Move{From: g1, To: f3, Piece: Knight}, "g1f3"},
+		{Move{From: e1, To: g1, Piece: King}, "e1g1"},
+		{Move{From: e8, To: c8, Piece: King}, "e8c8"},
+		{Move{From: d5, To: e6, Piece: Pawn, CapturePiece: Pawn, EP: true}, "d5e6"},
+		{Move{From: b7, To: a8, Piece: Pawn, CapturePiece: Rook, PromotePiece: Queen}, "b7a8q"},
+		{Move{From: b2, To: b1, Piece: Pawn, PromotePiece: Knight}, "b2b1n"},
+	} {
+		if got := Coordinate(test.move); got != test.want {
+			t.Errorf("Coordinate(%+v): got %v, want %v", test.move, got, test.want)
+		}
+	}
+}
+
 var moveAlgebraicTests = []struct {
 	fen    string
 	num    string
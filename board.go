@@ -131,30 +131,6 @@ var (
 	Ranks       = []Board{Rank1, Rank2, Rank3, Rank4, Rank5, Rank6, Rank7, Rank8}
 	fileLetters = []string{"a", "b", "c", "d", "e", "f", "g", "h"}
 	rankNumbers = []string{"1", "2", "3", "4", "5", "6", "7", "8"}
-
-	// CastleEmptySquares describes the squares that must be empty when castling.
-	CastleEmptySquares = [][]Board{
-		{
-			(BFile | CFile | DFile) & Rank1,
-			(FFile | GFile) & Rank1,
-		},
-		{
-			(BFile | CFile | DFile) & Rank8,
-			(FFile | GFile) & Rank8,
-		},
-	}
-
-	// CastleKingSquares describes the squares that the king occupies during castling.
-	CastleKingSquares = [][]Board{
-		{
-			(CFile | DFile | EFile) & Rank1,
-			(EFile | FFile | GFile) & Rank1,
-		},
-		{
-			(CFile | DFile | EFile) & Rank8,
-			(EFile | FFile | GFile) & Rank8,
-		},
-	}
 )
 
 // Color represents the color of a chess piece. A piece's color determines when it can move and whether it can move to an occupied Square.
@@ -202,6 +178,14 @@ type Position struct {
 	// Castle[c][side] indicates whether the c retains the option of castling to side, if it is legal to do so.
 	Castle [2][2]bool
 
+	// CastleFile[c][side] is the file of the rook with which c may castle to side, if Castle[c][side] is set.
+	// It is consulted only in Chess960; otherwise the castling rooks are on the a- and h-files.
+	CastleFile [2][2]byte
+
+	// Chess960 reports whether the Position belongs to a game of Chess960 (Fischer Random Chess).
+	// Castling Moves in Chess960 are encoded as the king capturing its own rook.
+	Chess960 bool
+
 	// ep is the unique square, if any, to which an en passant capture can be played by the side to move.
 	// Valid values are in the ranges [16, 24) (the 3rd rank, with Black to move following a White pawn push)
 	// and [40, 48) (the 6th rank, with White to move following a Black pawn push).
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPolyglotKey(t *testing.T) {
+	// Keys from the Polyglot book format specification, and after queen moves from another implementation.
+	for _, test := range []struct {
+		moves string
+		want  uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+		{"e2e4 e7e5 d1h5", 0xa849703c42999f3c},
+		{"e2e4 e7e5 d1h5 d8f6", 0xaf71edfb2770dcd1},
+		{"e2e4 e7e5 d1h5 d8f6 h5f7", 0x5799ab99e1d6d7f1},
+	} {
+		pos := InitialPosition
+		for _, s := range strings.Fields(test.moves) {
+			m, err := ParseCoordinate(pos, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pos = Make(pos, m)
+		}
+		if got := PolyglotKey(pos); got != test.want {
+			t.Errorf("%v: got %#x, want %#x", test.moves, got, test.want)
+		}
+	}
+}
+
+func TestPolyglotMove(t *testing.T) {
+	for _, test := range []struct {
+		fen  string
+		move string
+		want uint16
+	}{
+		{InitialPositionFEN, "e2e4", 1<<9 | 4<<6 | 3<<3 | 4},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1g1", 0<<9 | 4<<6 | 0<<3 | 7},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1c1", 0<<9 | 4<<6 | 0<<3 | 0},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8g8", 7<<9 | 4<<6 | 7<<3 | 7},
+		{"8/1P6/8/8/8/8/8/k1K5 w - - 0 1", "b7b8n", 1<<12 | 6<<9 | 1<<6 | 7<<3 | 1},
+		{"8/1P6/8/8/8/8/8/k1K5 w - - 0 1", "b7b8q", 4<<12 | 6<<9 | 1<<6 | 7<<3 | 1},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ParseCoordinate(pos, test.move)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := encodePolyglotMove(pos, m); got != test.want {
+			t.Errorf("%v %v: got %#x, want %#x", test.fen, test.move, got, test.want)
+		}
+		if got, ok := decodePolyglotMove(pos, test.want); !ok || got != m {
+			t.Errorf("%v %#x: got %v, %v, want %v, true", test.fen, test.want, got, ok, m)
+		}
+	}
+}
+
+func TestBook(t *testing.T) {
+	pos := InitialPosition
+	key := PolyglotKey(pos)
+	e4, _ := ParseCoordinate(pos, "e2e4")
+	d4, _ := ParseCoordinate(pos, "d2d4")
+	c4, _ := ParseCoordinate(pos, "c2c4")
+	var buf bytes.Buffer
+	if err := WriteBook(&buf, Book{
+		{Key: key, Move: encodePolyglotMove(pos, e4), Weight: 3},
+		{Key: key, Move: encodePolyglotMove(pos, d4), Weight: 1},
+		{Key: key, Move: encodePolyglotMove(pos, c4)},                  // zero weight
+		{Key: key, Move: 1<<9 | 4<<6 | 4<<3 | 4, Weight: 5},            // e2e5 is illegal
+		{Key: key + 1, Move: encodePolyglotMove(pos, c4), Weight: 100}, // another position
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadBook(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moves := b.Moves(pos); len(moves) != 2 || moves[0] != (BookMove{e4, 3}) || moves[1] != (BookMove{d4, 1}) {
+		t.Errorf("got %v, want [{%v 3} {%v 1}]", moves, e4, d4)
+	}
+	if m, ok := b.Pick(pos, true); !ok || m != e4 {
+		t.Errorf("best: got %v, %v, want %v, true", m, ok, e4)
+	}
+	for i := 0; i < 20; i++ {
+		if m, ok := b.Pick(pos, false); !ok || (m != e4 && m != d4) {
+			t.Errorf("random: got %v, %v, want %v or %v, true", m, ok, e4, d4)
+		}
+	}
+	if m, ok := b.Pick(Make(pos, e4), false); ok {
+		t.Errorf("out of book: got %v, true, want false", m)
+	}
+
+	if _, m := (Computer{book: b, bookBest: true}).Play(pos, nil); m != e4 {
+		t.Errorf("Computer.Play: got %v, want %v", m, e4)
+	}
+
+	if _, err := ReadBook(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); err == nil {
+		t.Error("truncated book: got nil error")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// A BookBuilder aggregates the results of the moves played in a collection of games, from which it builds a Book.
+type BookBuilder struct {
+	// MaxPly is the number of plies at the start of each game that are added.
+	MaxPly int
+
+	stats map[bookMoveKey]*bookStats
+	games int
+}
+
+// bookMoveKey identifies a Polyglot-encoded move in the position with a Polyglot key.
+type bookMoveKey struct {
+	key  uint64
+	move uint16
+}
+
+// bookStats counts the games in which a move was played by their result for the side that played it.
+type bookStats struct{ wins, draws, losses int }
+
+// games returns the number of games counted by s.
+func (s bookStats) games() int { return s.wins + s.draws + s.losses }
+
+// NewBookBuilder returns a BookBuilder adding maxPly plies of each game.
+func NewBookBuilder(maxPly int) *BookBuilder {
+	return &BookBuilder{MaxPly: maxPly, stats: make(map[bookMoveKey]*bookStats)}
+}
+
+// Add replays the main line of g and counts its result for each of the first MaxPly moves.
+// It reports whether g was added, which it is not if its result is unknown.
+func (bb *BookBuilder) Add(g *Game) bool {
+	result := g.Result()
+	if result == "*" {
+		return false
+	}
+	pos := g.Start()
+	for i, m := range g.MainLine() {
+		if i >= bb.MaxPly {
+			break
+		}
+		k := bookMoveKey{PolyglotKey(pos), encodePolyglotMove(pos, m)}
+		s, ok := bb.stats[k]
+		if !ok {
+			s = new(bookStats)
+			bb.stats[k] = s
+		}
+		switch {
+		case result == "1/2-1/2":
+			s.draws++
+		case (result == "1-0") == (pos.ToMove == White):
+			s.wins++
+		default:
+			s.losses++
+		}
+		pos = Make(pos, m)
+	}
+	bb.games++
+	return true
+}
+
+// Book returns a Book of the moves that were played in at least minGames of the added games.
+// Each move is weighted by twice its wins plus its draws, scaled if necessary so that the weights
+// of the moves in each position fit the Polyglot format. Moves whose weight is zero, which are never chosen, are omitted.
+func (bb *BookBuilder) Book(minGames int) Book {
+	weights := make(map[bookMoveKey]int)
+	max := make(map[uint64]int)
+	for k, s := range bb.stats {
+		if s.games() < minGames {
+			continue
+		}
+		w := 2*s.wins + s.draws
+		weights[k] = w
+		if w > max[k.key] {
+			max[k.key] = w
+		}
+	}
+	var b Book
+	for k, w := range weights {
+		if m := max[k.key]; m > math.MaxUint16 {
+			w = w * math.MaxUint16 / m
+		}
+		if w == 0 {
+			continue
+		}
+		b = append(b, BookEntry{Key: k.key, Move: k.move, Weight: uint16(w)})
+	}
+	sort.Slice(b, func(i, j int) bool {
+		switch {
+		case b[i].Key != b[j].Key:
+			return b[i].Key < b[j].Key
+		case b[i].Weight != b[j].Weight:
+			return b[i].Weight > b[j].Weight
+		default:
+			return b[i].Move < b[j].Move
+		}
+	})
+	return b
+}
+
+// bookCommand runs the book command with args, the command-line arguments that follow it.
+// "book build [flags] file.pgn..." builds a Polyglot book from the games in the named PGN files.
+func bookCommand(args []string) error {
+	if len(args) == 0 || args[0] != "build" {
+		return errors.New("usage: book build [flags] file.pgn...")
+	}
+	fs := flag.NewFlagSet("book build", flag.ExitOnError)
+	var (
+		out      = fs.String("o", "book.bin", "the name of the Polyglot book file to write")
+		maxPly   = fs.Int("ply", 16, "the number of plies of each game to add")
+		minGames = fs.Int("min", 3, "the number of games in which a move must have been played to be added")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: book build [flags] file.pgn...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	bb := NewBookBuilder(*maxPly)
+	for _, name := range fs.Args() {
+		if err := bb.addPGNFile(name, os.Stderr); err != nil {
+			return err
+		}
+	}
+	b := bb.Book(*minGames)
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := WriteBook(f, b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%v games, %v entries written to %v\n", bb.games, len(b), *out)
+	return nil
+}
+
+// addPGNFile adds the games in the named PGN file to bb.
+// Games that are not valid PGN are skipped, and their errors are reported to w.
+func (bb *BookBuilder) addPGNFile(name string, w io.Writer) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pr := NewPGNReader(f)
+	for n := 1; ; n++ {
+		g, err := pr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if _, ok := err.(*pgnSyntaxError); ok {
+			fmt.Fprintf(w, "%v: game %v: %v\n", name, n, err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%v: game %v: %v", name, n, err)
+		}
+		bb.Add(g)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testBookPGN = `[Result "1-0"]
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[Result "1-0"]
+1. e4 e5 2. Nf3 d6 1-0
+
+[Result "1/2-1/2"]
+1. e4 c5 1/2-1/2
+
+[Result "0-1"]
+1. d4 d5 0-1
+
+[Result "*"]
+1. c4 *
+`
+
+func TestBookBuilder(t *testing.T) {
+	bb := NewBookBuilder(3)
+	pr := NewPGNReader(strings.NewReader(testBookPGN))
+	for _, want := range []bool{true, true, true, true, false} {
+		g, err := pr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bb.Add(g); got != want {
+			t.Errorf("Add(%v): got %v, want %v", g.MainLine(), got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBook(&buf, bb.Book(1)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadBook(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		moves string
+		want  string // the book moves in order, with weights
+	}{
+		// e4: 2 wins and a draw; d4, which only lost, is omitted
+		{"", "e2e4:5"},
+		// c5: a draw; e5, which lost twice, is omitted
+		{"e2e4", "c7c5:1"},
+		// d5: a win
+		{"d2d4", "d7d5:2"},
+		{"e2e4 e7e5", "g1f3:4"},
+		// beyond 3 plies
+		{"e2e4 e7e5 g1f3", ""},
+		{"c2c4", ""},
+	} {
+		pos := InitialPosition
+		for _, s := range strings.Fields(test.moves) {
+			m, err := ParseCoordinate(pos, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pos = Make(pos, m)
+		}
+		var got []string
+		key := PolyglotKey(pos)
+		for _, e := range b {
+			if e.Key == key {
+				m, _ := decodePolyglotMove(pos, e.Move)
+				got = append(got, fmt.Sprintf("%v%v:%v", m.From, m.To, e.Weight))
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%q: got %q, want %q", test.moves, strings.Join(got, " "), test.want)
+		}
+	}
+
+	if b := bb.Book(2); len(b) != 2 {
+		t.Errorf("minimum 2 games: got %v entries, want 2 (e4 and Nf3)", len(b))
+	}
+}
+
+func TestBookBuilderPolyglotKeys(t *testing.T) {
+	// Keys from the Polyglot book format specification, which other tools use to find the moves.
+	bb := NewBookBuilder(2)
+	pr := NewPGNReader(strings.NewReader(testBookPGN))
+	for {
+		g, err := pr.Read()
+		if err != nil {
+			break
+		}
+		bb.Add(g)
+	}
+	var buf bytes.Buffer
+	if err := WriteBook(&buf, bb.Book(1)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadBook(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		key  uint64
+		fen  string
+		want string
+	}{
+		{0x463b96181691fc9c, InitialPositionFEN, "e2e4:5"},
+		{0x823c9b50fd114196, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "c7c5:1"},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range b {
+			if e.Key == test.key {
+				m, _ := decodePolyglotMove(pos, e.Move)
+				got = append(got, fmt.Sprintf("%v%v:%v", m.From, m.To, e.Weight))
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%#x: got %q, want %q", test.key, strings.Join(got, " "), test.want)
+		}
+	}
+}
+
+func TestBookBuilderPGNFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "games.pgn")
+	pgn := `[Result "1-0"]
+1. e4 e5 1-0
+
+[Result "1-0"]
+1. e4 e6 2. Ke3 1-0
+
+[Result "0-1"]
+1. d4 d5 0-1
+`
+	if err := os.WriteFile(name, []byte(pgn), 0666); err != nil {
+		t.Fatal(err)
+	}
+	bb := NewBookBuilder(2)
+	var errs bytes.Buffer
+	if err := bb.addPGNFile(name, &errs); err != nil {
+		t.Fatal(err)
+	}
+	if bb.games != 2 {
+		t.Errorf("got %v games, want 2", bb.games)
+	}
+	if got := errs.String(); !strings.HasPrefix(got, name+": game 2: ") || strings.Count(got, "\n") != 1 {
+		t.Errorf("got errors %q, want one for game 2", got)
+	}
+	if err := bb.addPGNFile(filepath.Join(t.TempDir(), "missing.pgn"), &errs); err == nil {
+		t.Error("missing file: got nil error")
+	}
+}
+
+func TestBookBuilderScaling(t *testing.T) {
+	bb := NewBookBuilder(1)
+	e4 := NewGame(InitialPosition, []Move{{From: e2, To: e4, Piece: Pawn}}, nil, "1-0")
+	d4 := NewGame(InitialPosition, []Move{{From: d2, To: d4, Piece: Pawn}}, nil, "1-0")
+	for i := 0; i < 40000; i++ {
+		bb.Add(e4)
+	}
+	for i := 0; i < 10000; i++ {
+		bb.Add(d4)
+	}
+	// A rare move's weight is scaled to zero, so it is omitted.
+	bb.Add(NewGame(InitialPosition, []Move{{From: c2, To: c4, Piece: Pawn}}, nil, "1/2-1/2"))
+	b := bb.Book(1)
+	if len(b) != 2 || b[0].Weight != 0xffff || b[1].Weight != 0xffff/4 {
+		t.Errorf("got %+v, want weights %v and %v", b, 0xffff, 0xffff/4)
+	}
+}
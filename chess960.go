@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Chess960FEN returns the FEN record of the Chess960 starting position numbered n in [0, 960)
+// in the scheme of Reinhard Scharnagl. Position 518 is the standard initial position.
+func Chess960FEN(n int) (string, error) {
+	if n < 0 || n >= 960 {
+		return "", fmt.Errorf("Chess960FEN: invalid position number %v", n)
+	}
+	var rank [8]byte
+	// place puts p on the ith empty square.
+	place := func(p byte, i int) {
+		for f := range rank {
+			if rank[f] != 0 {
+				continue
+			}
+			if i == 0 {
+				rank[f] = p
+				return
+			}
+			i--
+		}
+	}
+	rank[2*(n%4)+1] = 'B' // light-squared bishop
+	n /= 4
+	rank[2*(n%4)] = 'B' // dark-squared bishop
+	n /= 4
+	place('Q', n%6)
+	n /= 6
+	// The knights occupy two of the five remaining squares.
+	knights := [10][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}[n]
+	place('N', knights[1])
+	place('N', knights[0])
+	// The king stands between the rooks.
+	place('R', 0)
+	place('K', 0)
+	place('R', 0)
+
+	white := string(rank[:])
+	return fmt.Sprintf("%v/pppppppp/8/8/8/8/PPPPPPPP/%v w KQkq - 0 1", strings.ToLower(white), white), nil
+}
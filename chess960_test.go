@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestChess960FEN(t *testing.T) {
+	for _, test := range []struct {
+		n    int
+		want string
+	}{
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1"},
+		{518, InitialPositionFEN},
+		{959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w KQkq - 0 1"},
+	} {
+		if got, err := Chess960FEN(test.n); got != test.want || err != nil {
+			t.Errorf("Chess960FEN(%v): got %v, %v; want %v", test.n, got, err, test.want)
+		}
+	}
+	for _, n := range []int{-1, 960} {
+		if _, err := Chess960FEN(n); err == nil {
+			t.Errorf("Chess960FEN(%v): got nil error", n)
+		}
+	}
+	for n := 0; n < 960; n++ {
+		fen, _ := Chess960FEN(n)
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatalf("Chess960FEN(%v): %v", n, err)
+		}
+		if k := pos.KingSquare[White]; pos.castleRook(White, QS) >= k || k >= pos.castleRook(White, KS) {
+			t.Errorf("Chess960FEN(%v): king not between rooks in %v", n, fen)
+		}
+	}
+}
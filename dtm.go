@@ -0,0 +1,688 @@
+package main
+
+import (
+	"compress/flate"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DTM holds distance-to-mate tables of endings with few pieces, which bandit generates by retrograde analysis.
+// A table gives the number of plies to checkmate under perfect play from each position of its material,
+// disregarding the fifty-move rule.
+type DTM struct {
+	// tables holds the tables by material, as named by syzygyMaterial with White first.
+	tables map[string]dtmEntry
+
+	// generated holds the tables that were generated rather than read, in the order of their generation.
+	generated []*dtmTable
+}
+
+// A dtmEntry is a table of a material, with the colors reversed if flip is set.
+type dtmEntry struct {
+	t    *dtmTable
+	flip bool
+}
+
+// dtmTables is the distance-to-mate tables that searches probe, or nil if none are used.
+var dtmTables *DTM
+
+// dtmMaxPieces is the greatest number of pieces, including the kings, of any table that can be generated.
+const dtmMaxPieces = 4
+
+// dtmExt is the extension of the name of a table file.
+const dtmExt = ".dtm"
+
+// dtmMagic begins a table file. It is followed by the length of the material, the material,
+// and the values of the table compressed by DEFLATE.
+const dtmMagic = "BDTM"
+
+// NewDTM returns an empty DTM, whose tables are added by generating them.
+func NewDTM() *DTM {
+	return &DTM{tables: make(map[string]dtmEntry)}
+}
+
+// OpenDTM returns a DTM holding the tables in the named directory.
+func OpenDTM(dir string) (*DTM, error) {
+	tbs := NewDTM()
+	if err := tbs.load(dir); err != nil {
+		return nil, err
+	}
+	if len(tbs.tables) == 0 {
+		return nil, fmt.Errorf("%v: no distance-to-mate tables found", dir)
+	}
+	return tbs, nil
+}
+
+// load adds the tables in the named directory to tbs.
+func (tbs *DTM) load(dir string) error {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+dtmExt))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		t, err := readDTMTable(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+		tbs.add(t)
+	}
+	return nil
+}
+
+// add adds t to tbs under its material and the material with the colors reversed.
+func (tbs *DTM) add(t *dtmTable) {
+	tbs.tables[t.material] = dtmEntry{t: t}
+	if w, b, _ := strings.Cut(t.material, "v"); w != b {
+		tbs.tables[b+"v"+w] = dtmEntry{t: t, flip: true}
+	}
+}
+
+// probe returns the score of pos, and reports whether tbs holds pos. Positions with castling rights
+// or an en passant capture are not held.
+func (tbs *DTM) probe(pos Position) (Rel, bool) {
+	if tbs == nil || pos.Castle != [2][2]bool{} {
+		return Rel{}, false
+	}
+	if a, _ := eligibleEPCapturers(pos); a != 0 {
+		return Rel{}, false
+	}
+	e, ok := tbs.tables[syzygyMaterial(pos, White)]
+	if !ok {
+		return Rel{}, false
+	}
+	d := e.t.dtm(e.t.index(pos, e.flip))
+	if d < 0 {
+		return Rel{err: errTablebaseDraw}, true
+	}
+	return Rel{err: checkmateError(d)}, true
+}
+
+// holds reports whether tbs holds pos.
+func (tbs *DTM) holds(pos Position) bool {
+	_, ok := tbs.probe(pos)
+	return ok
+}
+
+// value returns the number of plies to checkmate from pos under perfect play, or -1 if it is drawn,
+// generating the table of its material if tbs does not hold it.
+func (tbs *DTM) value(pos Position) (int, error) {
+	if pos.b[White][All]|pos.b[Black][All] == pos.b[White][King]|pos.b[Black][King] {
+		return -1, nil
+	}
+	material := syzygyMaterial(pos, White)
+	e, ok := tbs.tables[material]
+	if !ok {
+		name, err := normalizeMaterial(material)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tbs.generate(name); err != nil {
+			return 0, err
+		}
+		e = tbs.tables[material]
+	}
+	return e.t.dtm(e.t.index(pos, e.flip)), nil
+}
+
+// normalizeMaterial returns the name of the table of material, such as KQvKR: the stronger side's pieces,
+// then "v", then the other side's, each beginning with the king and ordered as syzygyMaterial orders them.
+// The stronger side has more pieces, or else the more valuable piece where they first differ.
+func normalizeMaterial(material string) (string, error) {
+	const order = "KQRBNP"
+	w, b, ok := strings.Cut(strings.ToUpper(material), "V")
+	sides := []string{w, b}
+	for i, side := range sides {
+		if !ok || !strings.HasPrefix(side, "K") || strings.Count(side, "K") != 1 || strings.Trim(side, order) != "" {
+			return "", fmt.Errorf("invalid material %q", material)
+		}
+		s := []byte(side)
+		sort.Slice(s, func(i, j int) bool { return strings.IndexByte(order, s[i]) < strings.IndexByte(order, s[j]) })
+		sides[i] = string(s)
+	}
+	if n := len(sides[0]) + len(sides[1]); n > dtmMaxPieces {
+		return "", fmt.Errorf("%v: tables of more than %v pieces are not supported", material, dtmMaxPieces)
+	}
+	if strings.Contains(sides[0], "P") && strings.Contains(sides[1], "P") {
+		return "", fmt.Errorf("%v: tables in which both sides have pawns are not supported", material)
+	}
+	if strongerMaterial(sides[1], sides[0]) {
+		sides[0], sides[1] = sides[1], sides[0]
+	}
+	return sides[0] + "v" + sides[1], nil
+}
+
+// strongerMaterial reports whether the pieces of side a, as ordered by normalizeMaterial, are stronger than those of side b.
+func strongerMaterial(a, b string) bool {
+	const order = "KQRBNP"
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return strings.IndexByte(order, a[i]) < strings.IndexByte(order, b[i])
+		}
+	}
+	return false
+}
+
+// A dtmTable holds the distance to mate of each position of one material.
+//
+// A position is indexed by the side to move and the squares of its pieces, in the order of pieces. White's king
+// is restricted by the symmetries of the board to the squares of dtmKingSquares, and of the equivalent positions
+// the one with the least index is held. The other positions' values are 0.
+type dtmTable struct {
+	material string
+
+	// pieces holds White's king, White's other pieces, Black's king, and Black's other pieces, as ordered by
+	// syzygyMaterial, so that identical pieces are adjacent.
+	pieces []dtmPiece
+
+	// pawns reports whether the table has pawns, which restrict its symmetries to reflection between the a- and h-files.
+	pawns bool
+
+	// values holds 0 for a drawn or invalid position and otherwise 1 more than the number of plies to checkmate,
+	// which is odd if the side to move delivers it.
+	values []byte
+}
+
+// A dtmPiece is a piece of a dtmTable.
+type dtmPiece struct {
+	c Color
+	p Piece
+}
+
+var (
+	// dtmSymmetries holds the transformations of a square by which positions are equivalent: the first 2
+	// for positions with pawns, and all 8 for positions without.
+	dtmSymmetries [8][64]Square
+
+	// dtmKingSquares holds the squares to which White's king is restricted, without and with pawns,
+	// and dtmKingKeys the index of each square among them, followed by the other squares.
+	dtmKingSquares [2][]Square
+	dtmKingKeys    [2][64]int
+)
+
+func init() {
+	for s := a1; s <= h8; s++ {
+		t := (s>>3 | s<<3) & 63
+		for i, f := range []Square{s, s ^ 7, s ^ 56, s ^ 63, t, t ^ 7, t ^ 56, t ^ 63} {
+			dtmSymmetries[i][s] = f
+		}
+	}
+	// Without pawns, White's king is on the a1-d1-d4 triangle; with pawns, on the a- to d-files.
+	dtmKingSquares[0] = []Square{a1, b1, c1, d1, b2, c2, d2, c3, d3, d4}
+	for s := a1; s <= h8; s++ {
+		if s.File() < 4 {
+			dtmKingSquares[1] = append(dtmKingSquares[1], s)
+		}
+	}
+	for i, squares := range dtmKingSquares {
+		var used Board
+		for k, s := range squares {
+			dtmKingKeys[i][s] = k
+			used |= s.Board()
+		}
+		k := len(squares)
+		for s := a1; s <= h8; s++ {
+			if used&s.Board() == 0 {
+				dtmKingKeys[i][s] = k
+				k++
+			}
+		}
+	}
+}
+
+// newDTMTable returns a table of material, as named by normalizeMaterial, with no values.
+func newDTMTable(material string) (*dtmTable, error) {
+	if name, err := normalizeMaterial(material); err != nil {
+		return nil, err
+	} else if name != material {
+		return nil, fmt.Errorf("invalid material %q", material)
+	}
+	t := &dtmTable{material: material, pawns: strings.Contains(material, "P")}
+	w, b, _ := strings.Cut(material, "v")
+	for c, side := range []string{w, b} {
+		for _, r := range side {
+			t.pieces = append(t.pieces, dtmPiece{Color(c), Piece(strings.IndexRune(" PNBRQK", r))})
+		}
+	}
+	return t, nil
+}
+
+// pawnIndex returns 1 if t has pawns and otherwise 0, to index dtmKingSquares and dtmKingKeys.
+func (t *dtmTable) pawnIndex() int {
+	if t.pawns {
+		return 1
+	}
+	return 0
+}
+
+// size returns the number of indices of t.
+func (t *dtmTable) size() int {
+	n := 2 * len(dtmKingSquares[t.pawnIndex()])
+	for range t.pieces[1:] {
+		n *= 64
+	}
+	return n
+}
+
+// dtm returns the number of plies to checkmate from the position with index idx, or -1 if it is drawn.
+func (t *dtmTable) dtm(idx int) int {
+	return int(t.values[idx]) - 1
+}
+
+// index returns the index of pos, with the colors reversed if flip is set.
+func (t *dtmTable) index(pos Position, flip bool) int {
+	idx, _ := t.canonicalPosition(pos, flip)
+	return idx
+}
+
+// canonicalPosition returns the index of pos, with the colors reversed if flip is set,
+// and the number of symmetries of the board that leave it unchanged.
+func (t *dtmTable) canonicalPosition(pos Position, flip bool) (idx, stab int) {
+	var squares [dtmMaxPieces]Square
+	for i := 0; i < len(t.pieces); {
+		pc := t.pieces[i]
+		for b := pos.b[pc.c^boolColor(flip)][pc.p]; b != 0; b &= b - 1 {
+			if squares[i] = LS1BIndex(b); flip {
+				squares[i] ^= 56
+			}
+			i++
+		}
+	}
+	return t.canonical(pos.ToMove^boolColor(flip), squares[:len(t.pieces)])
+}
+
+// boolColor returns Black if b is set and otherwise White, to reverse a Color by exclusive or.
+func boolColor(b bool) Color {
+	if b {
+		return Black
+	}
+	return White
+}
+
+// canonical returns the least index of the positions equivalent to that with stm to move and the pieces of t
+// on squares, and the number of symmetries of the board that leave the position unchanged.
+func (t *dtmTable) canonical(stm Color, squares []Square) (idx, stab int) {
+	syms := dtmSymmetries[:]
+	if t.pawns {
+		syms = syms[:2]
+	}
+	pi := t.pawnIndex()
+	var first, best [dtmMaxPieces]Square
+	for i := range syms {
+		var cur [dtmMaxPieces]Square
+		for j, s := range squares {
+			cur[j] = syms[i][s]
+		}
+		// Identical pieces are interchangeable, so their squares are put in order.
+		for j := 1; j < len(squares); j++ {
+			for k := j; k > 0 && t.pieces[k-1] == t.pieces[k] && cur[k-1] > cur[k]; k-- {
+				cur[k-1], cur[k] = cur[k], cur[k-1]
+			}
+		}
+		if i == 0 {
+			first, best = cur, cur
+		}
+		if cur == first {
+			stab++
+		}
+		if k, b := dtmKingKeys[pi][cur[0]], dtmKingKeys[pi][best[0]]; k < b || k == b && lessSquares(cur[1:], best[1:]) {
+			best = cur
+		}
+	}
+	idx = int(stm)*len(dtmKingSquares[pi]) + dtmKingKeys[pi][best[0]]
+	for _, s := range best[1:len(squares)] {
+		idx = idx*64 + int(s)
+	}
+	return idx, stab
+}
+
+// lessSquares reports whether a precedes b in lexicographic order.
+func lessSquares(a, b []Square) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// position returns the position with index idx, and reports whether it is a legal position held by t.
+// The Position's Zobrist bitstring is not set.
+func (t *dtmTable) position(idx int) (Position, bool) {
+	var pos Position
+	var squares [dtmMaxPieces]Square
+	n, pi, i := len(t.pieces), t.pawnIndex(), idx
+	for j := n - 1; j > 0; j-- {
+		squares[j] = Square(i % 64)
+		i /= 64
+	}
+	kings := len(dtmKingSquares[pi])
+	squares[0], pos.ToMove, pos.FullMove = dtmKingSquares[pi][i%kings], Color(i/kings), 1
+	for j, pc := range t.pieces {
+		b := squares[j].Board()
+		if (pos.b[White][All]|pos.b[Black][All])&b != 0 || pc.p == Pawn && (squares[j].Rank() == 0 || squares[j].Rank() == 7) {
+			return pos, false
+		}
+		pos.b[pc.c][pc.p] |= b
+		pos.b[pc.c][All] |= b
+		if pc.p == King {
+			pos.KingSquare[pc.c] = squares[j]
+		}
+	}
+	if IsAttacked(pos, pos.KingSquare[pos.Opp()], pos.ToMove) {
+		return pos, false
+	}
+	c, _ := t.canonical(pos.ToMove, squares[:n])
+	return pos, c == idx
+}
+
+// unmoves returns the Positions from which a move that is neither a capture nor a promotion reaches pos,
+// disregarding castling rights, en passant, and the move counters. Their Zobrist bitstrings are not set.
+func unmoves(pos Position) []Position {
+	c := pos.Opp()
+	empty := ^(pos.b[White][All] | pos.b[Black][All])
+	var prev []Position
+	for _, p := range []Piece{Pawn, Knight, Bishop, Rook, Queen, King} {
+		for b := pos.b[c][p]; b != 0; b &= b - 1 {
+			to := LS1BIndex(b)
+			var from Board
+			switch p {
+			case Pawn:
+				from = pawnUnpushes(c, to, empty)
+			case Knight:
+				from = knightAttacks(to.Board(), empty)
+			case Bishop:
+				from = bishopAttacks(to.Board(), empty)
+			case Rook:
+				from = rookAttacks(to.Board(), empty)
+			case Queen:
+				from = bishopAttacks(to.Board(), empty) | rookAttacks(to.Board(), empty)
+			case King:
+				from = kingAttacks(to.Board(), empty)
+			}
+			for from &= empty; from != 0; from &= from - 1 {
+				f := LS1BIndex(from)
+				q := pos
+				q.b[c][p] ^= to.Board() | f.Board()
+				q.b[c][All] ^= to.Board() | f.Board()
+				if p == King {
+					q.KingSquare[c] = f
+				}
+				q.ToMove, q.ep = c, 0
+				// The side that did not move cannot have been left in check.
+				if IsAttacked(q, q.KingSquare[pos.ToMove], c) {
+					continue
+				}
+				prev = append(prev, q)
+			}
+		}
+	}
+	return prev
+}
+
+// pawnUnpushes returns a Board of the squares from which a pawn of color c may have been pushed to s
+// when there are no pieces at empty.
+func pawnUnpushes(c Color, s Square, empty Board) Board {
+	var from Board
+	switch {
+	case c == White && s.Rank() >= 2:
+		from = (s - 8).Board() & empty
+		if s.Rank() == 3 {
+			from |= from >> 8
+		}
+	case c == Black && s.Rank() <= 5:
+		from = (s + 8).Board() & empty
+		if s.Rank() == 4 {
+			from |= from << 8
+		}
+	}
+	return from
+}
+
+// generate returns the table of material, as named by normalizeMaterial, generating it and the tables of the endings
+// to which its captures and promotions lead unless tbs holds them.
+//
+// The positions in which the side to move is checkmated are resolved first. A position in which the side to move
+// can reach a position lost in n plies is then won in n+1, and one in which every move reaches a position won
+// in at most n plies is lost in n+1. The positions never resolved are drawn.
+func (tbs *DTM) generate(material string) (*dtmTable, error) {
+	if e, ok := tbs.tables[material]; ok {
+		return e.t, nil
+	}
+	t, err := newDTMTable(material)
+	if err != nil {
+		return nil, err
+	}
+	n := t.size()
+	t.values = make([]byte, n)
+	var (
+		// remaining holds the number of moves of each position within the table whose outcome is unknown,
+		// escape whether a move leaves the table to a position that is not won for the opponent,
+		// and exitMax the greatest distance to mate of the positions won for the opponent outside it.
+		remaining = make([]byte, n)
+		escape    = make([]bool, n)
+		exitMax   = make([]byte, n)
+
+		// scheduled holds 1 more than the distance at which each position was scheduled to be resolved,
+		// and buckets the positions scheduled at each distance.
+		scheduled = make([]byte, n)
+		buckets   [][]int32
+	)
+	schedule := func(idx, d int) error {
+		if d > 254 {
+			return fmt.Errorf("%v: checkmate beyond 254 plies", material)
+		}
+		if s := int(scheduled[idx]); s != 0 && s <= d+1 {
+			return nil
+		}
+		scheduled[idx] = byte(d + 1)
+		for len(buckets) <= d {
+			buckets = append(buckets, nil)
+		}
+		buckets[d] = append(buckets[d], int32(idx))
+		return nil
+	}
+
+	for idx := 0; idx < n; idx++ {
+		pos, ok := t.position(idx)
+		if !ok {
+			continue
+		}
+		moves := LegalMoves(pos)
+		if len(moves) == 0 {
+			if IsCheck(pos) {
+				schedule(idx, 0)
+			}
+			continue
+		}
+		for _, m := range moves {
+			if !m.IsCapture() && !m.IsPromotion() {
+				remaining[idx]++
+				continue
+			}
+			d, err := tbs.value(Make(pos, m))
+			switch {
+			case err != nil:
+				return nil, err
+			case d < 0:
+				escape[idx] = true
+			case d%2 == 0:
+				escape[idx] = true
+				if err := schedule(idx, d+1); err != nil {
+					return nil, err
+				}
+			case d > int(exitMax[idx]):
+				exitMax[idx] = byte(d)
+			}
+		}
+		if remaining[idx] == 0 && !escape[idx] {
+			if err := schedule(idx, int(exitMax[idx])+1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	type pred struct{ idx, stab int }
+	var preds []pred
+	for d := 0; d < len(buckets); d++ {
+		for _, i := range buckets[d] {
+			idx := int(i)
+			if t.values[idx] != 0 || int(scheduled[idx]) != d+1 {
+				continue
+			}
+			t.values[idx] = byte(d + 1)
+			pos, _ := t.position(idx)
+			_, stab := t.canonicalPosition(pos, false)
+			preds = preds[:0]
+			for _, q := range unmoves(pos) {
+				qi, qs := t.canonicalPosition(q, false)
+				preds = append(preds, pred{qi, qs})
+			}
+			sort.Slice(preds, func(i, j int) bool { return preds[i].idx < preds[j].idx })
+			for j := 0; j < len(preds); {
+				q := preds[j]
+				k := j
+				for k < len(preds) && preds[k].idx == q.idx {
+					k++
+				}
+				count := k - j
+				j = k
+				if t.values[q.idx] != 0 {
+					continue
+				}
+				if d%2 == 0 {
+					// The position is lost, so its predecessors are won.
+					if err := schedule(q.idx, d+1); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				// The position is won, so one fewer move of each predecessor remains unknown for each of its moves
+				// that reaches a position equivalent to it. Those number count scaled by the ratio of the symmetries
+				// that leave each position unchanged.
+				remaining[q.idx] -= byte(count * q.stab / stab)
+				if remaining[q.idx] == 0 && !escape[q.idx] {
+					if err := schedule(q.idx, max(d, int(exitMax[q.idx]))+1); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		buckets[d] = nil
+	}
+	tbs.add(t)
+	tbs.generated = append(tbs.generated, t)
+	return t, nil
+}
+
+// writeDTMTable writes t to w in the format of a table file.
+func writeDTMTable(w io.Writer, t *dtmTable) error {
+	header := append([]byte(dtmMagic), byte(len(t.material)))
+	if _, err := w.Write(append(header, t.material...)); err != nil {
+		return err
+	}
+	fw, err := flate.NewWriter(w, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(t.values); err != nil {
+		return err
+	}
+	return fw.Close()
+}
+
+// readDTMTable reads a table from r in the format of a table file.
+func readDTMTable(r io.Reader) (*dtmTable, error) {
+	header := make([]byte, len(dtmMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:len(dtmMagic)]) != dtmMagic {
+		return nil, errors.New("not a distance-to-mate table")
+	}
+	material := make([]byte, header[len(dtmMagic)])
+	if _, err := io.ReadFull(r, material); err != nil {
+		return nil, err
+	}
+	t, err := newDTMTable(string(material))
+	if err != nil {
+		return nil, err
+	}
+	fr := flate.NewReader(r)
+	defer fr.Close()
+	t.values = make([]byte, t.size())
+	if _, err := io.ReadFull(fr, t.values); err != nil {
+		return nil, fmt.Errorf("%v: %v", t.material, err)
+	}
+	if n, _ := fr.Read(make([]byte, 1)); n != 0 {
+		return nil, fmt.Errorf("%v: too many values", t.material)
+	}
+	return t, nil
+}
+
+// tablebaseCommand runs the tablebase command with args, the command-line arguments that follow it.
+// "tablebase generate [flags] material..." generates the distance-to-mate tables of the named endings, such as KRvK,
+// and of the endings to which they lead.
+func tablebaseCommand(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return errors.New("usage: tablebase generate [flags] material...")
+	}
+	fs := flag.NewFlagSet("tablebase generate", flag.ExitOnError)
+	dir := fs.String("dir", ".", "the directory in which to write the tables, and from which to read those already generated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tablebase generate [flags] material...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	tbs := NewDTM()
+	if err := tbs.load(*dir); err != nil {
+		return err
+	}
+	for _, arg := range fs.Args() {
+		material, err := normalizeMaterial(arg)
+		if err != nil {
+			return err
+		}
+		if _, err := tbs.generate(material); err != nil {
+			return err
+		}
+	}
+	for _, t := range tbs.generated {
+		name := filepath.Join(*dir, t.material+dtmExt)
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := writeDTMTable(f, t); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		var longest int
+		for _, v := range t.values {
+			longest = max(longest, int(v)-1)
+		}
+		fmt.Printf("%v: longest checkmate in %v plies, written to %v\n", t.material, longest, name)
+	}
+	return nil
+}
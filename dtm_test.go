@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestNormalizeMaterial(t *testing.T) {
+	for _, test := range []struct {
+		material, want string
+	}{
+		{"KQvK", "KQvK"},
+		{"KvKQ", "KQvK"},
+		{"kbnvk", "KBNvK"},
+		{"KRvKQ", "KQvKR"},
+		{"KPvKR", "KRvKP"},
+		{"KBvKB", "KBvKB"},
+		{"KPvKP", ""},
+		{"KQRvKR", ""},
+		{"KQK", ""},
+		{"QvKK", ""},
+		{"KXvK", ""},
+	} {
+		got, err := normalizeMaterial(test.material)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%v: got %v, want error", test.material, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%v: got %v, %v, want %v", test.material, got, err, test.want)
+		}
+	}
+}
+
+func TestUnmoves(t *testing.T) {
+	for _, fen := range []string{
+		"8/8/8/3k4/8/8/4P3/R3K3 b - - 0 1",
+		"4k3/8/8/8/4P3/8/8/4K3 b - - 0 1",
+		"8/8/8/8/8/2k5/8/K1q5 w - - 0 1",
+		"8/4p3/8/8/8/8/1k6/4K3 w - - 0 1",
+	} {
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each unmove is undone by a legal move that is neither a capture nor a promotion.
+		prev := unmoves(pos)
+		for _, q := range prev {
+			found := false
+			for _, m := range LegalMoves(q) {
+				if child := Make(q, m); !m.IsCapture() && !m.IsPromotion() && child.b == pos.b {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%v: unmove to %v reaches no legal move", fen, q.b)
+			}
+		}
+		// Each such move of a position reached by an unmove is found as an unmove of its child.
+		for _, q := range prev {
+			for _, m := range LegalMoves(q) {
+				if m.IsCapture() || m.IsPromotion() {
+					continue
+				}
+				found := false
+				for _, r := range unmoves(Make(q, m)) {
+					found = found || r.b == q.b
+				}
+				if !found {
+					t.Errorf("%v: %v from %v not found as an unmove", fen, Coordinate(m), q.b)
+				}
+			}
+		}
+	}
+}
+
+// checkDTMTable checks that each value of t is given by those of the positions its moves reach.
+func checkDTMTable(t *testing.T, tbs *DTM, tb *dtmTable) {
+	t.Helper()
+	for idx := range tb.values {
+		pos, ok := tb.position(idx)
+		if !ok {
+			if tb.values[idx] != 0 {
+				t.Fatalf("%v: invalid index %v has value %v", tb.material, idx, tb.values[idx])
+			}
+			continue
+		}
+		want, win, loss := -1, -1, -1
+		moves := LegalMoves(pos)
+		for _, m := range moves {
+			d, err := tbs.value(Make(pos, m))
+			switch {
+			case err != nil:
+				t.Fatal(err)
+			case d < 0:
+				loss = 1000
+			case d%2 == 0 && (win < 0 || d+1 < win):
+				win = d + 1
+			case d%2 == 1 && d+1 > loss:
+				loss = d + 1
+			}
+		}
+		switch {
+		case len(moves) == 0 && IsCheck(pos):
+			want = 0
+		case win >= 0:
+			want = win
+		case len(moves) > 0 && loss < 1000:
+			want = loss
+		}
+		if got := tb.dtm(idx); got != want {
+			t.Fatalf("%v %v: got %v, want %v", tb.material, FEN(pos), got, want)
+		}
+	}
+}
+
+func TestGenerateDTM(t *testing.T) {
+	tbs := NewDTM()
+	for _, test := range []struct {
+		material string
+		longest  int // the longest checkmate with White to move, in plies
+	}{
+		{"KQvK", 19},
+		{"KRvK", 31},
+		{"KPvK", 55},
+	} {
+		tb, err := tbs.generate(test.material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		longest := 0
+		for idx, v := range tb.values[:len(tb.values)/2] {
+			if d := int(v) - 1; d > longest {
+				if d%2 == 0 {
+					t.Fatalf("%v: White is checkmated in index %v", test.material, idx)
+				}
+				longest = d
+			}
+		}
+		if longest != test.longest {
+			t.Errorf("%v: got longest checkmate in %v plies, want %v", test.material, longest, test.longest)
+		}
+		checkDTMTable(t, tbs, tb)
+	}
+
+	for _, test := range []struct {
+		fen  string
+		want Rel
+	}{
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", Rel{err: checkmateError(43)}},
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", Rel{err: checkmateError(21)}},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", Rel{err: checkmateError(24)}},
+		{"4k3/4P3/4K3/8/8/8/8/8 w - - 0 1", Rel{err: checkmateError(17)}},
+		// stalemate
+		{"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1", Rel{err: errTablebaseDraw}},
+		{"7k/8/8/8/8/8/7P/7K w - - 0 1", Rel{err: errTablebaseDraw}},
+		// Black's king cannot catch the pawn.
+		{"8/k7/8/8/8/8/7P/7K b - - 0 1", Rel{err: checkmateError(28)}},
+		// The colors are reversed.
+		{"7k/7p/8/8/8/8/K7/8 w - - 0 1", Rel{err: checkmateError(28)}},
+		// castling rights
+		{"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", Rel{}},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := tbs.probe(pos)
+		if ok != (test.want != Rel{}) || got != test.want {
+			t.Errorf("%v: got %v, %v, want %v", test.fen, got, ok, test.want)
+		}
+	}
+}
+
+func TestDTMFile(t *testing.T) {
+	tbs := NewDTM()
+	tb, err := tbs.generate("KRvK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeDTMTable(&buf, tb); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	got, err := readDTMTable(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.material != tb.material || !bytes.Equal(got.values, tb.values) {
+		t.Errorf("got table %v, want %v with the same values", got.material, tb.material)
+	}
+	if _, err := readDTMTable(bytes.NewReader(data[:len(data)-8])); err == nil {
+		t.Error("truncated table: got nil error")
+	}
+	if _, err := readDTMTable(bytes.NewReader([]byte("not a table"))); err == nil {
+		t.Error("invalid table: got nil error")
+	}
+}
+
+func TestSearchDTM(t *testing.T) {
+	defer func(tt *TransTable, tbs *DTM) { hashTable, dtmTables = tt, tbs }(hashTable, dtmTables)
+	hashTable, dtmTables = NewTransTable(1), NewDTM()
+	for _, material := range []string{"KRvK", "KPvK"} {
+		if _, err := dtmTables.generate(material); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, fen := range []string{
+		"8/8/8/4k3/8/8/8/R3K3 w - - 0 1",
+		"8/8/8/4K3/8/8/8/r3k3 b - - 0 1",
+		"8/8/8/8/8/2k5/8/K6r w - - 0 1",
+		"7k/8/8/8/8/8/7P/7K w - - 0 1", // drawn
+	} {
+		hashTable.Clear()
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := dtmTables.probe(pos)
+		var info Info
+		rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: 2, OnIteration: func(i Info) { info = i }})
+		if got := rs[0].score.Rel(pos.ToMove); got != want {
+			t.Errorf("%v: got %v, want %v", fen, got, want)
+		}
+		if info.TBHits == 0 {
+			t.Errorf("%v: got 0 tablebase hits", fen)
+		}
+	}
+}
+
+// reverseColors returns the FEN of pos with the colors of its pieces and the side to move reversed and the board flipped.
+func reverseColors(pos Position) string {
+	f := strings.Fields(FEN(pos))
+	ranks := strings.Split(f[0], "/")
+	slices.Reverse(ranks)
+	f[0] = strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, strings.Join(ranks, "/"))
+	f[1] = map[string]string{"w": "b", "b": "w"}[f[1]]
+	return strings.Join(f, " ")
+}
+
+// TestSyzygyDTM probes Syzygy tables whose values are those of the distance-to-mate tables of the same material,
+// and checks the WDL and DTZ of every position against its distance to mate.
+func TestSyzygyDTM(t *testing.T) {
+	dir := t.TempDir()
+	dtm := NewDTM()
+	for _, test := range []struct {
+		material string
+		pieces   []int
+		dtzSTM   Color // the side to move in the DTZ table
+		longest  int   // the longest DTZ with White to move, in plies
+	}{
+		{"KRvK", []int{6, 4, 14}, White, 31},
+		{"KQvK", []int{6, 5, 14}, Black, 19},
+	} {
+		dt, err := dtm.generate(test.material)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tb, _ := newSyzygyTable("", test.material, false)
+		d := &tb.pairs[0][0]
+		copy(d.pieces[:], test.pieces)
+		d.setGroups(tb, [2]int{0, 0xf}, 0)
+		var wdlValues [2][]int
+		for i := range wdlValues {
+			wdlValues[i] = make([]int, d.size())
+		}
+		dtzValues := make([]int, d.size())
+		for _, values := range [][]int{wdlValues[0], wdlValues[1], dtzValues} {
+			for i := range values {
+				values[i] = -1
+			}
+		}
+		for idx := range dt.values {
+			pos, ok := dt.position(idx)
+			if !ok {
+				continue
+			}
+			rook := LS1BIndex(pos.b[White][All] &^ pos.b[White][King])
+			i := tb.encode(d, []Square{pos.KingSquare[White], rook, pos.KingSquare[Black]}, 0)
+			v := dt.dtm(idx)
+			switch {
+			case v < 0:
+				wdlValues[pos.ToMove][i] = int(wdlDraw) + 2
+			case v%2 == 1:
+				wdlValues[pos.ToMove][i] = int(wdlWin) + 2
+			default:
+				wdlValues[pos.ToMove][i] = int(wdlLoss) + 2
+			}
+			if pos.ToMove == test.dtzSTM && v >= 0 {
+				dtzValues[i] = max(v, 1) - 1
+			}
+		}
+		// Indices of no legal position hold the value of the index before them.
+		for _, values := range [][]int{wdlValues[0], wdlValues[1], dtzValues} {
+			for i := range values {
+				if values[i] < 0 {
+					values[i] = 0
+					if i > 0 {
+						values[i] = values[i-1]
+					}
+				}
+			}
+		}
+
+		writeSyzygyFile(t, filepath.Join(dir, test.material+syzygyWDLExt), false, test.pieces,
+			syzygyTestEncoding{values: wdlValues[White]}, syzygyTestEncoding{values: wdlValues[Black]})
+		dtzEncoding := syzygyTestEncoding{flags: syzygyWinPlies | syzygyLossPlies, values: dtzValues}
+		if test.dtzSTM == Black {
+			// Map the values of losses, in plies, through the identity.
+			dtzEncoding.flags |= syzygySTM | syzygyMapped
+			for v := 0; v <= slices.Max(dtzValues); v++ {
+				dtzEncoding.maps[1] = append(dtzEncoding.maps[1], v)
+			}
+		}
+		writeSyzygyFile(t, filepath.Join(dir, test.material+syzygyDTZExt), true, test.pieces, dtzEncoding)
+		syz, err := OpenSyzygy(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		longest := 0
+		for idx := range dt.values {
+			pos, ok := dt.position(idx)
+			if !ok {
+				continue
+			}
+			v := dt.dtm(idx)
+			wantWDL, wantDTZ := wdlDraw, 0
+			switch {
+			case v < 0:
+			case v%2 == 1:
+				wantWDL, wantDTZ = wdlWin, v
+			default:
+				wantWDL, wantDTZ = wdlLoss, -max(v, 1)
+			}
+			for _, fen := range []string{FEN(pos), reverseColors(pos)} {
+				p, err := ParseFEN(fen)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if w, ok := syz.probeWDL(p); !ok || w != wantWDL {
+					t.Fatalf("%v: got WDL %v, %v, want %v", fen, w, ok, wantWDL)
+				}
+				if dtz, ok := syz.probeDTZ(p); !ok || dtz != wantDTZ {
+					t.Fatalf("%v: got DTZ %v, %v, want %v", fen, dtz, ok, wantDTZ)
+				} else if p.ToMove == White && dtz > longest {
+					longest = dtz
+				}
+			}
+		}
+		if d := syz.dtz[test.material].get(0, 0); d.numBlocks < 2 || len(d.symLen) < 8 {
+			t.Errorf("%v: got %v blocks and %v symbols, want more", test.material, d.numBlocks, len(d.symLen))
+		}
+		if longest != test.longest {
+			t.Errorf("%v: got longest DTZ %v, want %v", test.material, longest, test.longest)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EPD is a Position in Extended Position Description format, as test suites record it, with its operations.
+type EPD struct {
+	Pos Position
+
+	// Ops holds the operands of each operation by opcode.
+	Ops map[string][]string
+
+	// ID and Comment are the operands of the id and c0 operations, if any.
+	ID, Comment string
+
+	// BestMoves and AvoidMoves hold the Moves of the bm and am operations, if any.
+	BestMoves, AvoidMoves []Move
+
+	// Mate, if positive, is the operand of the dm operation: the number of moves in which the side to move
+	// delivers checkmate.
+	Mate int
+}
+
+// ParseEPD parses an EPD record: the first four fields of an FEN record, followed by operations, each of which
+// is an opcode and its operands ending with a semicolon. An operand containing spaces is enclosed in double quotes.
+// The hmvc and fmvn operations give the half-move clock and full-move number, which are otherwise 0 and 1.
+func ParseEPD(s string) (*EPD, error) {
+	var fields []string
+	rest := strings.TrimSpace(s)
+	for len(fields) < 4 && rest != "" {
+		i := strings.IndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			i = len(rest)
+		}
+		fields, rest = append(fields, rest[:i]), strings.TrimSpace(rest[i:])
+	}
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("ParseEPD: %v fields (need 4)", len(fields))
+	}
+	ops, err := parseEPDOps(rest)
+	if err != nil {
+		return nil, err
+	}
+	halfMove, fullMove := "0", "1"
+	if o := ops["hmvc"]; len(o) > 0 {
+		halfMove = o[0]
+	}
+	if o := ops["fmvn"]; len(o) > 0 {
+		fullMove = o[0]
+	}
+	pos, err := ParseFEN(strings.Join(append(fields, halfMove, fullMove), " "))
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EPD{Pos: pos, Ops: ops}
+	if o := ops["id"]; len(o) > 0 {
+		e.ID = o[0]
+	}
+	if o := ops["c0"]; len(o) > 0 {
+		e.Comment = o[0]
+	}
+	for _, op := range []struct {
+		opcode string
+		moves  *[]Move
+	}{{"bm", &e.BestMoves}, {"am", &e.AvoidMoves}} {
+		for _, san := range ops[op.opcode] {
+			m, err := ParseAlgebraic(pos, san)
+			if err != nil {
+				return nil, fmt.Errorf("ParseEPD: %v: %v", op.opcode, err)
+			}
+			*op.moves = append(*op.moves, m)
+		}
+	}
+	if o, ok := ops["dm"]; ok {
+		if len(o) != 1 {
+			return nil, fmt.Errorf("ParseEPD: dm: %v operands (need 1)", len(o))
+		}
+		if e.Mate, err = strconv.Atoi(o[0]); err != nil || e.Mate <= 0 {
+			return nil, fmt.Errorf("ParseEPD: dm: invalid number of moves %q", o[0])
+		}
+	}
+	return e, nil
+}
+
+// parseEPDOps parses the operations of an EPD record and returns the operands of each by opcode.
+// The semicolon ending the last operation may be omitted.
+func parseEPDOps(s string) (map[string][]string, error) {
+	ops := make(map[string][]string)
+	var fields []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		switch s[0] {
+		case ';':
+			if len(fields) == 0 {
+				return nil, errors.New("ParseEPD: operation without opcode")
+			}
+			ops[fields[0]], fields, s = fields[1:], nil, s[1:]
+		case '"':
+			i := strings.IndexByte(s[1:], '"')
+			if i < 0 {
+				return nil, fmt.Errorf("ParseEPD: unterminated string %v", s)
+			}
+			fields, s = append(fields, s[1:i+1]), s[i+2:]
+		default:
+			i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == ';' || r == '"' })
+			if i < 0 {
+				i = len(s)
+			}
+			fields, s = append(fields, s[:i]), s[i:]
+		}
+	}
+	if len(fields) > 0 {
+		ops[fields[0]] = fields[1:]
+	}
+	return ops, nil
+}
+
+// Scored reports whether e has a bm, am, or dm operation by which to judge a search.
+func (e *EPD) Scored() bool {
+	return len(e.BestMoves) > 0 || len(e.AvoidMoves) > 0 || e.Mate > 0
+}
+
+// Solved reports whether a search of e's Position that prefers m with score solves it: m is one of its best moves,
+// and none of the moves to avoid, and score is a checkmate by the side to move in at most Mate moves, if any of these are given.
+func (e *EPD) Solved(m Move, score Rel) bool {
+	if len(e.BestMoves) > 0 && !containsMove(e.BestMoves, m) {
+		return false
+	}
+	if containsMove(e.AvoidMoves, m) {
+		return false
+	}
+	if e.Mate <= 0 {
+		return true
+	}
+	n, ok := score.err.(checkmateError)
+	return ok && n&1 != 0 && n <= checkmateError(2*e.Mate-1)
+}
+
+// containsMove reports whether moves contains m.
+func containsMove(moves []Move, m Move) bool {
+	for _, n := range moves {
+		if n.From == m.From && n.To == m.To && n.PromotePiece == m.PromotePiece {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the operations by which e is judged, with the moves in standard algebraic notation.
+func (e *EPD) target() string {
+	var ops []string
+	for _, op := range []struct {
+		opcode string
+		moves  []Move
+	}{{"bm", e.BestMoves}, {"am", e.AvoidMoves}} {
+		if len(op.moves) > 0 {
+			sans := []string{op.opcode}
+			for _, m := range op.moves {
+				sans = append(sans, Algebraic(e.Pos, m))
+			}
+			ops = append(ops, strings.Join(sans, " "))
+		}
+	}
+	if e.Mate > 0 {
+		ops = append(ops, fmt.Sprintf("dm %v", e.Mate))
+	}
+	return strings.Join(ops, "; ")
+}
+
+// ReadEPDFile returns the EPD records in the named file, one per line. Blank lines are skipped,
+// as are lines that are not valid EPD, whose errors are reported to w.
+func ReadEPDFile(name string, w io.Writer) ([]*EPD, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var epds []*EPD
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		e, err := ParseEPD(scanner.Text())
+		if err != nil {
+			fmt.Fprintf(w, "%v:%v: %v\n", name, line, err)
+			continue
+		}
+		epds = append(epds, e)
+	}
+	return epds, scanner.Err()
+}
+
+// runTestSuite searches the Position of each of epds as configured by opts, for at most moveTime if it is positive,
+// or for a checkmate in at most the given number of moves if it has a dm operation, and writes to w whether the search solved it and how long it took, followed by a summary.
+// It returns the number of positions solved and the number judged, which omits those without a bm, am, or dm operation.
+func runTestSuite(w io.Writer, epds []*EPD, moveTime time.Duration, opts SearchOptions) (solved, judged int) {
+	start := time.Now()
+	for i, e := range epds {
+		name := e.ID
+		if name == "" {
+			name = fmt.Sprintf("#%v", i+1)
+		}
+		if !e.Scored() {
+			fmt.Fprintf(w, "%v: skipped: no bm, am, or dm operation\n", name)
+			continue
+		}
+		judged++
+		if hashTable != nil {
+			hashTable.Clear()
+		}
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if moveTime > 0 {
+			ctx, cancel = context.WithTimeout(ctx, moveTime)
+		}
+		o := opts
+		if e.Mate > 0 {
+			// The depth is that of the checkmate, and a longer one found by extending checks does not solve the position.
+			o.Depth, o.Mate = 0, e.Mate
+		}
+		searchStart := time.Now()
+		rs := SearchPosition(ctx, e.Pos, nil, o)
+		elapsed := time.Since(searchStart).Truncate(time.Millisecond)
+		cancel()
+		if len(rs) == 0 {
+			fmt.Fprintf(w, "%v: failed: no legal moves (%v) %v\n", name, e.target(), elapsed)
+			continue
+		}
+		verdict := "failed"
+		if e.Solved(rs[0].move, rs[0].score.Rel(e.Pos.ToMove)) {
+			verdict = "solved"
+			solved++
+		}
+		fmt.Fprintf(w, "%v: %v: %v %v (%v) %v\n", name, verdict, Algebraic(e.Pos, rs[0].move), rs[0].score, e.target(), elapsed)
+	}
+	if judged > 0 {
+		fmt.Fprintf(w, "%v of %v solved (%.1f%%) in %v\n", solved, judged, 100*float64(solved)/float64(judged), time.Since(start).Truncate(time.Millisecond))
+	}
+	return solved, judged
+}
+
+// testSuiteCommand runs the testsuite command with args, the command-line arguments that follow it.
+// "testsuite [flags] file.epd..." searches the positions of the named EPD files and reports those solved.
+func testSuiteCommand(args []string) error {
+	fs := flag.NewFlagSet("testsuite", flag.ExitOnError)
+	defaultTime := time.Second
+	var (
+		moveTime = fs.Duration("time", 0, fmt.Sprintf("the time per position (default %v if no depth or node limit set)", defaultTime))
+		depth    = fs.Int("depth", 0, "the search depth")
+		nodes    = fs.Int("nodes", 0, "the number of nodes to search per position")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: testsuite [flags] file.epd...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *moveTime <= 0 && *depth <= 0 && *nodes <= 0 {
+		*moveTime = defaultTime
+	}
+	if *depth <= 0 {
+		*depth = 100
+	}
+
+	var epds []*EPD
+	for _, name := range fs.Args() {
+		e, err := ReadEPDFile(name, os.Stderr)
+		if err != nil {
+			return err
+		}
+		epds = append(epds, e...)
+	}
+	runTestSuite(os.Stdout, epds, *moveTime, SearchOptions{Depth: *depth, Nodes: *nodes})
+	return nil
+}
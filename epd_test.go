@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEPD(t *testing.T) {
+	for _, test := range []struct {
+		epd       string
+		fen       string
+		id        string
+		bm, am    string // the moves in standard algebraic notation, separated by spaces
+		mate      int
+		comment   string
+		ops       map[string][]string
+		wantError bool
+	}{
+		{
+			epd: `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`,
+			fen: "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1",
+			id:  "WAC.001",
+			bm:  "Qg6",
+			ops: map[string][]string{"bm": {"Qg6"}, "id": {"WAC.001"}},
+		},
+		{
+			epd:     `r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - am Nxc6 Qd2; c0 "avoid losing a piece"; hmvc 3; fmvn 7`,
+			fen:     "r1b1k2r/ppppnppp/2n2q2/2b5/3NP3/2P1B3/PP3PPP/RN1QKB1R w KQkq - 3 7",
+			am:      "Nxc6 Qd2",
+			comment: "avoid losing a piece",
+			ops:     map[string][]string{"am": {"Nxc6", "Qd2"}, "c0": {"avoid losing a piece"}, "hmvc": {"3"}, "fmvn": {"7"}},
+		},
+		{
+			epd:  "6k1/5ppp/8/8/8/8/8/R5K1 w - - dm 1; bm Ra8+;",
+			fen:  "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+			bm:   "Ra8",
+			mate: 1,
+			ops:  map[string][]string{"dm": {"1"}, "bm": {"Ra8+"}},
+		},
+		{
+			epd: "6k1/5ppp/8/8/8/8/8/R5K1 w - -",
+			fen: "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+			ops: map[string][]string{},
+		},
+		{epd: "6k1/5ppp/8/8/8/8/8/R5K1 w -", wantError: true},
+		{epd: "6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Rb8;", wantError: true},
+		{epd: "6k1/5ppp/8/8/8/8/8/R5K1 w - - dm 0;", wantError: true},
+		{epd: `6k1/5ppp/8/8/8/8/8/R5K1 w - - id "unterminated;`, wantError: true},
+		{epd: "6k1/5ppp/8/8/8/8/8/R5K1 w - - ;", wantError: true},
+	} {
+		e, err := ParseEPD(test.epd)
+		if test.wantError {
+			if err == nil {
+				t.Errorf("%v: got nil error", test.epd)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.epd, err)
+			continue
+		}
+		if got := FEN(e.Pos); got != test.fen {
+			t.Errorf("%v: got FEN %v, want %v", test.epd, got, test.fen)
+		}
+		if e.ID != test.id || e.Comment != test.comment || e.Mate != test.mate {
+			t.Errorf("%v: got id %q, c0 %q, dm %v, want %q, %q, %v", test.epd, e.ID, e.Comment, e.Mate, test.id, test.comment, test.mate)
+		}
+		for _, moves := range []struct {
+			name string
+			got  []Move
+			want string
+		}{{"bm", e.BestMoves, test.bm}, {"am", e.AvoidMoves, test.am}} {
+			var sans []string
+			for _, m := range moves.got {
+				sans = append(sans, Algebraic(e.Pos, m))
+			}
+			if got := strings.TrimRight(strings.Join(sans, " "), "+#"); got != moves.want {
+				t.Errorf("%v: got %v %q, want %q", test.epd, moves.name, got, moves.want)
+			}
+		}
+		if !reflect.DeepEqual(e.Ops, test.ops) {
+			t.Errorf("%v: got operations %v, want %v", test.epd, e.Ops, test.ops)
+		}
+	}
+}
+
+func TestEPDSolved(t *testing.T) {
+	e, err := ParseEPD("6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8; am Ra7; dm 1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra8, _ := ParseAlgebraic(e.Pos, "Ra8")
+	ra7, _ := ParseAlgebraic(e.Pos, "Ra7")
+	mate := Rel{err: checkmateError(1)}
+	for _, test := range []struct {
+		m     Move
+		score Rel
+		want  bool
+	}{
+		{ra8, mate, true},
+		{ra8, Rel{n: 900}, false},
+		{ra7, mate, false},
+	} {
+		if got := e.Solved(test.m, test.score); got != test.want {
+			t.Errorf("Solved(%v, %v): got %v, want %v", Coordinate(test.m), test.score, got, test.want)
+		}
+	}
+
+	// A checkmate in at most the given number of moves by the side to move solves dm.
+	e, err = ParseEPD("6k1/5ppp/8/8/8/8/8/R5K1 w - - dm 2;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		score Rel
+		want  bool
+	}{
+		{Rel{err: checkmateError(1)}, true},
+		{Rel{err: checkmateError(3)}, true},
+		{Rel{err: checkmateError(5)}, false},
+		{Rel{err: checkmateError(2)}, false},
+		{Rel{n: 900}, false},
+	} {
+		if got := e.Solved(ra8, test.score); got != test.want {
+			t.Errorf("dm 2: Solved(%v, %v): got %v, want %v", Coordinate(ra8), test.score, got, test.want)
+		}
+	}
+}
+
+func TestReadEPDFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "suite.epd")
+	epd := `6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8; id "1";
+
+6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Rb8; id "2";
+6k1/5ppp/8/8/8/8/8/R5K1 w - - am Ra7; id "3";
+`
+	if err := os.WriteFile(name, []byte(epd), 0666); err != nil {
+		t.Fatal(err)
+	}
+	var errs bytes.Buffer
+	epds, err := ReadEPDFile(name, &errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, e := range epds {
+		ids = append(ids, e.ID)
+	}
+	if got := strings.Join(ids, " "); got != "1 3" {
+		t.Errorf("got records %v, want 1 3", got)
+	}
+	if got := errs.String(); !strings.HasPrefix(got, name+":3: ") || strings.Count(got, "\n") != 1 {
+		t.Errorf("got errors %q, want one for line 3", got)
+	}
+	if _, err := ReadEPDFile(filepath.Join(t.TempDir(), "missing.epd"), &errs); err == nil {
+		t.Error("missing file: got nil error")
+	}
+}
+
+func TestRunTestSuite(t *testing.T) {
+	var epds []*EPD
+	for _, s := range []string{
+		`6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8; id "back rank";`,
+		`6k1/5ppp/8/8/8/8/8/R5K1 w - - am Ra8; id "avoid mate";`,
+		`6k1/5ppp/8/8/8/8/8/R5K1 w - - id "unjudged";`,
+	} {
+		e, err := ParseEPD(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		epds = append(epds, e)
+	}
+	var buf bytes.Buffer
+	solved, judged := runTestSuite(&buf, epds, 0, SearchOptions{Depth: 2})
+	if solved != 1 || judged != 2 {
+		t.Errorf("got %v of %v solved, want 1 of 2", solved, judged)
+	}
+	out := buf.String()
+	for _, want := range []string{"back rank: solved: Ra8#", "avoid mate: failed: Ra8#", "unjudged: skipped", "1 of 2 solved (50.0%)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("got report\n%v\nwant it to contain %q", out, want)
+		}
+	}
+
+	// A position with a dm operation is searched for a checkmate to its depth, rather than the depth of opts.
+	epds = nil
+	for _, s := range []string{
+		`kbK5/pp6/1P6/8/8/8/8/R7 w - - dm 2; id "mate in 2";`,
+		`kbK5/pp6/1P6/8/8/8/8/R7 w - - dm 1; id "no mate in 1";`,
+	} {
+		e, err := ParseEPD(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		epds = append(epds, e)
+	}
+	buf.Reset()
+	solved, judged = runTestSuite(&buf, epds, 0, SearchOptions{Depth: 1})
+	if solved != 1 || judged != 2 {
+		t.Errorf("dm: got %v of %v solved, want 1 of 2", solved, judged)
+	}
+	out = buf.String()
+	for _, want := range []string{"mate in 2: solved: Ra6", "no mate in 1: failed: "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("got report\n%v\nwant it to contain %q", out, want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math/bits"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 var (
@@ -105,17 +107,34 @@ func ParseFEN(fen string) (pos Position, err error) {
 	}
 
 	// castling
-	if n := strings.IndexFunc(fields[2], func(char rune) bool {
-		return char != '-' && RuneToPiece[char] != Queen && RuneToPiece[char] != King
-	}); n != -1 {
-		return pos, fmt.Errorf("ParseFEN: Invalid character in castling field %v", fields[2])
-	}
+	// In addition to KQkq, accept the files of the castling rooks as in Shredder-FEN and X-FEN.
 	for _, char := range fields[2] {
-		switch RuneToPiece[char] {
-		case Queen:
-			pos.Castle[RuneToColor[char]][QS] = true
-		case King:
-			pos.Castle[RuneToColor[char]][KS] = true
+		if char == '-' {
+			continue
+		}
+		c, side := White, KS
+		if unicode.IsLower(char) {
+			c = Black
+		}
+		file := outerRookFile(pos, c, side)
+		switch char = unicode.ToUpper(char); {
+		case char == 'K':
+		case char == 'Q':
+			side = QS
+			file = outerRookFile(pos, c, side)
+		case 'A' <= char && char <= 'H':
+			file = byte(char - 'A')
+			if file < pos.KingSquare[c].File() {
+				side = QS
+			}
+			pos.Chess960 = true
+		default:
+			return pos, fmt.Errorf("ParseFEN: Invalid character in castling field %v", fields[2])
+		}
+		pos.Castle[c][side] = true
+		pos.CastleFile[c][side] = file
+		if pos.KingSquare[c].File() != 4 || file != [...]byte{0, 7}[side] {
+			pos.Chess960 = true
 		}
 	}
 
@@ -184,21 +203,27 @@ func FEN(pos Position) string {
 	case Black:
 		s += " b "
 	}
-	if pos.Castle[White][KS] {
-		s += "K"
-	}
-	if pos.Castle[White][QS] {
-		s += "Q"
-	}
-	if pos.Castle[Black][KS] {
-		s += "k"
-	}
-	if pos.Castle[Black][QS] {
-		s += "q"
+	var castle string
+	for c := White; c <= Black; c++ {
+		for _, side := range []Side{KS, QS} {
+			if !pos.Castle[c][side] {
+				continue
+			}
+			// As in X-FEN, a Chess960 castling rook is identified by its file if it is not the outermost rook.
+			char := []string{"Q", "K"}[side]
+			if file := pos.CastleFile[c][side]; pos.Chess960 && file != outerRookFile(pos, c, side) {
+				char = strings.ToUpper(fileLetters[file])
+			}
+			if c == Black {
+				char = strings.ToLower(char)
+			}
+			castle += char
+		}
 	}
-	if !(pos.Castle[White][KS] || pos.Castle[White][QS] || pos.Castle[Black][KS] || pos.Castle[Black][QS]) {
-		s += "-"
+	if castle == "" {
+		castle = "-"
 	}
+	s += castle
 	if pos.ep == 0 {
 		s += " - "
 	} else {
@@ -208,6 +233,20 @@ func FEN(pos Position) string {
 	return s
 }
 
+// outerRookFile returns the file of the outermost rook of Color c on its first rank on the side of its king,
+// or the a- or h-file if there is none.
+func outerRookFile(pos Position, c Color, side Side) byte {
+	rooks := pos.b[c][Rook] & Ranks[7*int(c)]
+	k := pos.KingSquare[c]
+	switch {
+	case side == QS && rooks&(k.Board()-1) != 0:
+		return LS1BIndex(rooks).File()
+	case side == KS && rooks&^(k.Board()<<1-1) != 0:
+		return Square(63 - bits.LeadingZeros64(uint64(rooks))).File()
+	}
+	return [...]byte{0, 7}[side]
+}
+
 func isWhite(r rune) bool  { return r == 'P' || r == 'N' || r == 'B' || r == 'R' || r == 'Q' || r == 'K' }
 func isBlack(r rune) bool  { return r == 'p' || r == 'n' || r == 'b' || r == 'r' || r == 'q' || r == 'k' }
 func isNumber(r rune) bool { return '1' <= r && r <= '8' }
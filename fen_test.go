@@ -171,3 +171,26 @@ func TestFEN(t *testing.T) {
 		}
 	}
 }
+
+func TestFENChess960(t *testing.T) {
+	for _, test := range []struct {
+		fen, want string
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9"},
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9"},
+		{"1r2k1rr/8/8/8/8/8/8/1R2K1RR w GBgb - 0 1", "1r2k1rr/8/8/8/8/8/8/1R2K1RR w GQgq - 0 1"},
+		{"1r2k1rr/8/8/8/8/8/8/1R2K1RR w GQgq - 0 1", "1r2k1rr/8/8/8/8/8/8/1R2K1RR w GQgq - 0 1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pos.Chess960 {
+			t.Errorf("ParseFEN(%v): not Chess960", test.fen)
+		}
+		if got := FEN(pos); got != test.want {
+			t.Errorf("FEN(ParseFEN(%v)): got %v, want %v", test.fen, got, test.want)
+		}
+	}
+}
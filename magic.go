@@ -0,0 +1,92 @@
+package main
+
+import "fmt"
+
+// A magic holds the data to look up the attacks of a sliding piece on a single square.
+// The occupied squares that can block the piece are masked, multiplied by a magic number,
+// and shifted to form an index into a table of precomputed attacks.
+type magic struct {
+	mask    Board
+	magic   uint64
+	shift   uint
+	attacks []Board
+}
+
+var (
+	bishopMagics [64]magic
+	rookMagics   [64]magic
+)
+
+// The magic numbers were found by trial of random numbers with few set bits.
+var (
+	bishopMagicNumbers = [64]uint64{
+		0x0020428400408200, 0x0004100440408000, 0x82041c2482010010, 0x0484142d80000000,
+		0x0002021008101042, 0x4200882008014100, 0x0004a81108200000, 0x0001004802011100,
+		0x1040401024010048, 0x0042041004204881, 0x2008110810810020, 0x240008060440c288,
+		0x0021020210050020, 0x0022810402408800, 0x802060410420a042, 0x0081020086481280,
+		0x4110400860c10400, 0x20040021240c0240, 0x4802044104040080, 0x00008868020042c0,
+		0x0002204400a00002, 0x2820408488084000, 0x08006024041c0420, 0x4102088190808810,
+		0x0102201040094200, 0xab0a0814203800a0, 0x4044209010008080, 0x2020120000400440,
+		0x4400840034802011, 0x425051000600a200, 0x412094012a010410, 0x08084080a04c0440,
+		0x0304022241c10401, 0x0200841000210200, 0x0602004100100100, 0x0c04020081080080,
+		0x00a0040400004102, 0x30348102000100a2, 0x10041401700c0500, 0x8000840282424212,
+		0x0810822110002000, 0x00204814500084a4, 0x0002022228009410, 0x200000420080a810,
+		0x0000200411108400, 0x8240100400400020, 0x01a4108404442100, 0x0a020a0201a20209,
+		0x00010c0920881a42, 0x022104022202a400, 0x80000022011008a0, 0x5000441020884001,
+		0x1001001202020008, 0x4808202102308024, 0x4008023004051104, 0x44040802004e0800,
+		0x000040a210066040, 0x000002060a01050d, 0x52a0040106431002, 0x0000040200841c08,
+		0xf000010828030409, 0x0041201120190500, 0x0404082081122212, 0x000408009c008200,
+	}
+
+	rookMagicNumbers = [64]uint64{
+		0x008000908064c000, 0x0040200040001000, 0x0180100080a0010a, 0x8880041000800800,
+		0x1200100201200804, 0x0200020004011008, 0x2180010000800600, 0x0200005088210204,
+		0x0000800080204001, 0x1000804000802001, 0x8240801000200080, 0x8f80801000800801,
+		0x008180800c001800, 0x0100800200800400, 0x0a02000102000408, 0x8020802300104280,
+		0x0080004000402000, 0xe010104000402000, 0x0800808010002000, 0xa280210008100100,
+		0x0001818014000800, 0xa002010100080400, 0x0080240001020870, 0x0001020004048845,
+		0x0081826280004004, 0x2020810900284000, 0x0200100080802000, 0x0001002100081000,
+		0x8083080100100500, 0x4406000901000400, 0x0005020080800100, 0x0090204200008114,
+		0x0010400094800420, 0x0900804000802002, 0x0201001841002000, 0x4100080080801000,
+		0x4540040080800800, 0x0002001004040020, 0x0281195814001002, 0x1240800040800100,
+		0x0880042000524004, 0x02c080410206002c, 0x0801200241050010, 0x8400080010008080,
+		0x0008000500090010, 0x0082009084020008, 0x01818902102c0008, 0x8308408041020004,
+		0x0200860c20410200, 0x6020200090400080, 0x0800900020008280, 0x0000100020090100,
+		0x0400800400080280, 0x0050044010200801, 0x0101004406000b00, 0xc100066400870200,
+		0x440680014012a501, 0x1023012082044112, 0x00804080200a0012, 0x000420310a004a42,
+		0x0023001004020801, 0x0882001008040102, 0x000230088118020c, 0x0000019025040042,
+	}
+)
+
+// index returns the index in m.attacks of the attacks for the occupied squares occ.
+func (m *magic) index(occ Board) uint64 { return uint64(occ&m.mask) * m.magic >> m.shift }
+
+func init() {
+	for s := a1; s <= h8; s++ {
+		b := s.Board()
+		// A piece on the edge of the board cannot block an attack beyond it, so the masks exclude the edges.
+		bishopMagics[s] = newMagic(b, slowBishopAttacks(b, ^b)&^(AFile|HFile|Rank1|Rank8), bishopMagicNumbers[s], slowBishopAttacks)
+		lines := Ranks[s.Rank()]&^(AFile|HFile) | Files[s.File()]&^(Rank1|Rank8)
+		rookMagics[s] = newMagic(b, slowRookAttacks(b, ^b)&lines, rookMagicNumbers[s], slowRookAttacks)
+	}
+}
+
+// newMagic returns the magic for the piece at p with the given blocker mask and magic number,
+// filling its attack table using attacks. It panics if the magic number is invalid.
+func newMagic(p, mask Board, number uint64, attacks func(p, empty Board) Board) magic {
+	n := PopCount(mask)
+	m := magic{mask: mask, magic: number, shift: uint(64 - n), attacks: make([]Board, 1<<uint(n))}
+	// Enumerate every subset of mask by the Carry-Rippler method.
+	for occ := Board(0); ; {
+		a := attacks(p, ^occ)
+		i := m.index(occ)
+		if m.attacks[i] != 0 && m.attacks[i] != a {
+			panic(fmt.Sprintf("invalid magic number %#x for %v", number, LS1BIndex(p)))
+		}
+		m.attacks[i] = a
+		if occ = (occ - mask) & mask; occ == 0 {
+			break
+		}
+	}
+	return m
+}
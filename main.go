@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"math/rand"
 	"os"
 	"time"
 )
@@ -11,49 +12,184 @@ import (
 func main() {
 	var (
 		defaultTime = 3 * time.Second
-		moveTime    = flag.Duration("time", 0, fmt.Sprintf("computer's time per move (default %v if no depth limit set)", defaultTime))
+		moveTime    = flag.Duration("time", 0, fmt.Sprintf("computer's time per move (default %v if no depth limit or time control set)", defaultTime))
+		tcFlag      = flag.String("tc", "", "the time control of the game clock as [moves/]minutes[+increment seconds], e.g. 5+3")
 		depth       = flag.Int("depth", 0, "the search depth")
+		nodes       = flag.Int("nodes", 0, "the number of nodes to search per move")
+		mate        = flag.Int("mate", 0, "search only for a checkmate in at most this many moves")
+		ponder      = flag.Bool("ponder", false, "computer searches on the opponent's time")
 		fen         = flag.String("fen", InitialPositionFEN, "the FEN record of the starting position")
 		humanWhite  = flag.Bool("w", false, "user plays White")
 		humanBlack  = flag.Bool("b", false, "user plays Black")
+		hash        = flag.Int("hash", DefaultHashSize, "transposition table size in megabytes (0 to disable)")
+		evasions    = flag.Bool("evasions", false, "search all check evasions in quiescence search")
+		pvs         = flag.Int("multipv", 1, "the number of principal variations to search to exact scores")
+		threads     = flag.Int("threads", 1, "the number of threads with which to search")
+		pvSearch    = flag.Bool("pvs", true, "search with principal variation search")
+		nullMove    = flag.Bool("nullmove", true, "search with null-move pruning")
+		lmr         = flag.Bool("lmr", true, "search with late move reductions")
+		checkExt    = flag.Bool("checkext", true, "search with check extensions")
+		aspiration  = flag.Bool("aspiration", true, "search each iteration within an aspiration window")
+		pgnFile     = flag.String("pgn", "", "write the finished game in PGN format to the named file")
+		bookFile    = flag.String("book", "", "play from the named Polyglot opening book before searching")
+		bookBest    = flag.Bool("bookbest", false, "play the book's most heavily weighted move instead of choosing by weight at random")
+		chess960    = flag.Bool("960", false, "play Chess960 from a random starting position")
+		syzygyPath  = flag.String("syzygy", "", "probe the Syzygy endgame tablebases in the named directories, separated as in PATH")
+		dtmDir      = flag.String("dtm", "", "probe the distance-to-mate tables generated in the named directory")
 	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %v [flags] [uci | xboard | book build [flags] file.pgn... | tablebase generate [flags] material... | testsuite [flags] file.epd...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
-	if *moveTime <= 0 {
-		*moveTime = 86164091 * time.Millisecond
-		if *depth <= 0 {
-			*moveTime = defaultTime
+	quiescenceEvasions = *evasions
+	principalVariationSearch, nullMovePruning, lateMoveReductions, checkExtensions = *pvSearch, *nullMove, *lmr, *checkExt
+	aspirationWindows = *aspiration
+	if *pvs > 1 {
+		multiPV = *pvs
+	}
+	if *threads > 1 {
+		searchThreads = *threads
+	}
+	if *hash > 0 {
+		hashTable = NewTransTable(*hash)
+	}
+	if *syzygyPath != "" {
+		tb, err := OpenSyzygy(*syzygyPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		tablebases = tb
+	}
+	if *dtmDir != "" {
+		tbs, err := OpenDTM(*dtmDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dtmTables = tbs
+	}
+
+	switch flag.Arg(0) {
+	case "":
+	case "uci":
+		if err := UCI(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "xboard":
+		if err := XBoard(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		return
+	case "book":
+		if err := bookCommand(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "tablebase":
+		if err := tablebaseCommand(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	case "testsuite":
+		if err := testSuiteCommand(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var clocks [2]*gameClock
+	if *tcFlag != "" {
+		tc, err := parseTimeControl(*tcFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		clocks = [2]*gameClock{newGameClock(tc), newGameClock(tc)}
+	}
+	var tm timeManager
+	switch {
+	case *moveTime > 0:
+		tm = fixedTime(*moveTime)
+	case clocks[White] == nil && *depth <= 0 && *nodes <= 0 && *mate <= 0:
+		tm = fixedTime(defaultTime)
 	}
 	if *depth <= 0 {
 		*depth = 100
 	}
 
+	if *chess960 {
+		var err error
+		if *fen, err = Chess960FEN(rand.Intn(960)); err != nil {
+			panic(err)
+		}
+	}
 	pos, err := ParseFEN(*fen)
 	if err != nil {
 		panic(err)
 	}
+	pos.Chess960 = pos.Chess960 || *chess960
 	startpos := pos
 
 	stdin := bufio.NewScanner(os.Stdin)
-	players := []Player{Computer{*moveTime, *depth}, Computer{*moveTime, *depth}}
+	computer := Computer{tm: tm, depth: *depth, nodes: *nodes, mate: *mate, bookBest: *bookBest}
+	if *bookFile != "" {
+		if computer.book, err = OpenBook(*bookFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	players := []Player{computer, computer}
 	if *humanWhite {
 		players[White] = Human{stdin}
 	}
 	if *humanBlack {
 		players[Black] = Human{stdin}
 	}
+	if *ponder {
+		for c, p := range players {
+			if computer, ok := p.(Computer); ok {
+				computer.ponder = new(ponderer)
+				defer computer.ponder.stop()
+				players[c] = computer
+			}
+		}
+	}
 
 	fmt.Println(pos)
 	startTime := time.Now()
 	var moves []Move
+	var evals []Abs
 	var resultText string
+	var history []Zobrist
 	posZobrists := make(map[Zobrist]int)
 
 game:
 	for {
-		moveTime := time.Now()
+		moveStart := time.Now()
 
-		score, move := players[pos.ToMove].Play(pos)
+		player, clock := players[pos.ToMove], clocks[pos.ToMove]
+		if c, ok := player.(Computer); ok && clock != nil && *moveTime <= 0 {
+			c.tm = clock.timeManager()
+			player = c
+		}
+		score, move := player.Play(pos, history)
+		elapsed := time.Since(moveStart)
+		if clock != nil && !clock.punch(elapsed) {
+			fmt.Printf("%v loses on time\n", pos.ToMove)
+			resultText = []string{"1-0", "0-1"}[pos.Opp()]
+			break
+		}
 		if move == (Move{}) {
 			// player resigns
 			resultText = []string{"1-0", "0-1"}[pos.Opp()]
@@ -62,12 +198,19 @@ game:
 
 		numalg := numberedAlgebraic(pos, move) // before Make
 		moves = append(moves, move)
+		history = append(history, pos.z)
 		pos = Make(pos, move)
 		if s, ok := score.err.(checkmateError); ok {
 			score = Abs{err: s.Next()}
 		}
 
-		fmt.Printf("%v %v %v\n", numalg, score, time.Since(moveTime).Truncate(time.Millisecond))
+		evals = append(evals, score)
+
+		if clock != nil {
+			fmt.Printf("%v %v %v (%v remaining)\n", numalg, score, elapsed.Truncate(time.Millisecond), clock.remaining.Truncate(time.Millisecond))
+		} else {
+			fmt.Printf("%v %v %v\n", numalg, score, elapsed.Truncate(time.Millisecond))
+		}
 		fmt.Println(pos)
 
 		// Check for end-of-game conditions
@@ -88,4 +231,40 @@ game:
 
 	fmt.Printf("%v %v\n", Text(startpos, moves), resultText)
 	fmt.Println(time.Since(startTime).Truncate(time.Millisecond))
+
+	if *pgnFile != "" {
+		g := NewGame(startpos, moves, evals, resultText)
+		g.Tags["Event"] = "bandit game"
+		g.Tags["Date"] = startTime.Format("2006.01.02")
+		if startpos.Chess960 {
+			g.Tags["Variant"] = "Chess960"
+		}
+		if clocks[White] != nil {
+			g.Tags["TimeControl"] = clocks[White].tc.pgn()
+		}
+		for c, p := range players {
+			name := "bandit"
+			if _, ok := p.(Human); ok {
+				name = "Human"
+			}
+			g.Tags[[]string{"White", "Black"}[c]] = name
+		}
+		if err := writePGNFile(*pgnFile, g); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// writePGNFile writes g in PGN format to the named file, creating it if necessary and otherwise appending to it.
+func writePGNFile(name string, g *Game) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err := WritePGN(f, g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -8,6 +8,7 @@ type Move struct {
 	CapturePiece Piece  // the Piece being captured, or else None
 	EP           bool   // whether the Move is an en passant capture
 	PromotePiece Piece  // the Piece being promoted to, or else None
+	Castle960    bool   // whether the Move castles in Chess960, in which case To is the Square of the castling rook
 }
 
 // IsCapture reports whether m is a capture.
@@ -27,6 +28,11 @@ func (m Move) IsCastle() (side Side, ok bool) {
 	switch {
 	case m.Piece != King:
 		return
+	case m.Castle960:
+		if m.To < m.From {
+			return QS, true
+		}
+		return KS, true
 	case m.From-m.To == 2:
 		return QS, true
 	case m.To-m.From == 2:
@@ -59,8 +65,20 @@ func Make(pos Position, m Move) Position {
 	}
 
 	// Move the piece
-	update(pos.ToMove, m.Piece, m.From)
-	update(pos.ToMove, m.Piece, m.To)
+	kingTo := m.To
+	if side, ok := m.IsCastle(); ok {
+		// Move the king and the castling rook.
+		// In Chess960 their origins and destinations may coincide, so both are removed before either is placed.
+		var rookFrom, rookTo Square
+		_, kingTo, rookFrom, rookTo = castleSquares(pos, side)
+		update(pos.ToMove, King, m.From)
+		update(pos.ToMove, Rook, rookFrom)
+		update(pos.ToMove, King, kingTo)
+		update(pos.ToMove, Rook, rookTo)
+	} else {
+		update(pos.ToMove, m.Piece, m.From)
+		update(pos.ToMove, m.Piece, m.To)
+	}
 
 	if m.IsCapture() {
 		captureSquare := m.To
@@ -70,33 +88,14 @@ func Make(pos Position, m Move) Position {
 		}
 		update(pos.Opp(), m.CapturePiece, captureSquare)
 		// Lose the relevant castling right
-		switch {
-		case (pos.ToMove == Black && m.To == a1) || (pos.ToMove == White && m.To == a8):
-			if pos.Castle[pos.Opp()][QS] {
-				pos.z.xor(castleZobrist[pos.Opp()][QS])
-			}
-			pos.Castle[pos.Opp()][QS] = false
-		case (pos.ToMove == Black && m.To == h1) || (pos.ToMove == White && m.To == h8):
-			if pos.Castle[pos.Opp()][KS] {
-				pos.z.xor(castleZobrist[pos.Opp()][KS])
+		for _, side := range [...]Side{QS, KS} {
+			if pos.Castle[pos.Opp()][side] && m.To == pos.castleRook(pos.Opp(), side) {
+				pos.z.xor(castleZobrist[pos.Opp()][side])
+				pos.Castle[pos.Opp()][side] = false
 			}
-			pos.Castle[pos.Opp()][KS] = false
 		}
 	}
 
-	switch side, ok := m.IsCastle(); {
-	case ok && side == QS:
-		// Move the castling rook
-		rookFrom, rookTo := m.From-4, m.From-1
-		update(pos.ToMove, Rook, rookFrom)
-		update(pos.ToMove, Rook, rookTo)
-	case ok && side == KS:
-		// Move the castling rook
-		rookFrom, rookTo := m.From+3, m.From+1
-		update(pos.ToMove, Rook, rookFrom)
-		update(pos.ToMove, Rook, rookTo)
-	}
-
 	if m.IsPromotion() {
 		// Replace the Pawn with PromotePiece
 		update(pos.ToMove, Pawn, m.To)
@@ -106,7 +105,7 @@ func Make(pos Position, m Move) Position {
 	switch m.Piece {
 	case King:
 		// Update KingSquare and forfeit all castling rights
-		pos.KingSquare[pos.ToMove] = m.To
+		pos.KingSquare[pos.ToMove] = kingTo
 		if pos.Castle[pos.ToMove][QS] {
 			pos.z.xor(castleZobrist[pos.ToMove][QS])
 		}
@@ -117,17 +116,11 @@ func Make(pos Position, m Move) Position {
 		pos.Castle[pos.ToMove][KS] = false
 	case Rook:
 		// Forfeit the relevant castling right
-		switch {
-		case (pos.ToMove == White && m.From == a1) || (pos.ToMove == Black && m.From == a8):
-			if pos.Castle[pos.ToMove][QS] {
-				pos.z.xor(castleZobrist[pos.ToMove][QS])
+		for _, side := range [...]Side{QS, KS} {
+			if pos.Castle[pos.ToMove][side] && m.From == pos.castleRook(pos.ToMove, side) {
+				pos.z.xor(castleZobrist[pos.ToMove][side])
+				pos.Castle[pos.ToMove][side] = false
 			}
-			pos.Castle[pos.ToMove][QS] = false
-		case (pos.ToMove == White && m.From == h1) || (pos.ToMove == Black && m.From == h8):
-			if pos.Castle[pos.ToMove][KS] {
-				pos.z.xor(castleZobrist[pos.ToMove][KS])
-			}
-			pos.Castle[pos.ToMove][KS] = false
 		}
 	}
 
@@ -159,18 +152,96 @@ func Make(pos Position, m Move) Position {
 	return pos
 }
 
-// PseudoLegalMoves returns all pseudo-legal Moves in pos.
-func PseudoLegalMoves(pos Position) []Move {
-	moves := make([]Move, 0, 100)
-	moves = append(moves, PawnMoves(pos)...)
-	moves = append(moves, KnightMoves(pos)...)
-	moves = append(moves, BishopMoves(pos)...)
-	moves = append(moves, RookMoves(pos)...)
-	moves = append(moves, QueenMoves(pos)...)
-	moves = append(moves, KingMoves(pos)...)
-
-	// Counting sort into the order winning captures, equal captures, losing captures, non-captures.
-	// (This terminology anticipates that the captured piece is defended and the capturing piece is liable to be captured in exchange.)
+// MakeNull passes the move in pos to the opponent and reports the resulting Position.
+// The half-move clock is reset, so that no repetition is found across the null move.
+// Behavior is undefined when pos is in check.
+func MakeNull(pos Position) Position {
+	if pos.ep != 0 {
+		if a, b := eligibleEPCapturers(pos); a != 0 {
+			pos.z.xor(canEPCaptureZobrist[a.File()])
+			if b != 0 {
+				pos.z.xor(canEPCaptureZobrist[b.File()])
+			}
+		}
+		pos.ep = 0
+	}
+	pos.HalfMove = 0
+	if pos.ToMove == Black {
+		pos.FullMove++
+	}
+	pos.ToMove = pos.Opp()
+	pos.z.xor(blackToMoveZobrist)
+	return pos
+}
+
+// maxMoves is the capacity of a MoveList. No legal chess position has more than 218 legal moves,
+// and none that can arise in a game has more than 256 pseudo-legal moves.
+const maxMoves = 256
+
+// MoveList is a list of Moves with a fixed capacity, so that generating moves does not allocate.
+// The zero value is an empty MoveList.
+type MoveList struct {
+	moves [maxMoves]Move
+	n     int
+}
+
+// Len returns the number of Moves in ml.
+func (ml *MoveList) Len() int { return ml.n }
+
+// Moves returns the Moves in ml. The returned slice refers to the storage of ml.
+func (ml *MoveList) Moves() []Move { return ml.moves[:ml.n] }
+
+// add appends m to ml.
+func (ml *MoveList) add(m Move) {
+	ml.moves[ml.n] = m
+	ml.n++
+}
+
+// GenPseudoLegalMoves replaces the contents of ml with all pseudo-legal Moves in pos.
+func GenPseudoLegalMoves(pos Position, ml *MoveList) {
+	ml.n = 0
+	genPawnMoves(pos, ml)
+	genPieceMoves(pos, Knight, ml)
+	genPieceMoves(pos, Bishop, ml)
+	genPieceMoves(pos, Rook, ml)
+	genPieceMoves(pos, Queen, ml)
+	genKingMoves(pos, ml)
+	ml.sort()
+}
+
+// GenLegalMoves replaces the contents of ml with all legal Moves in pos,
+// in the order in which GenPseudoLegalMoves generates them.
+func GenLegalMoves(pos Position, ml *MoveList) {
+	GenPseudoLegalMoves(pos, ml)
+	ml.filter(pos, newLegality(pos).isLegal)
+}
+
+// GenLegalCaptures replaces the contents of ml with all legal captures and queen promotions in pos,
+// in the order in which GenPseudoLegalMoves generates them.
+func GenLegalCaptures(pos Position, ml *MoveList) {
+	GenPseudoLegalMoves(pos, ml)
+	l := newLegality(pos)
+	ml.filter(pos, func(pos Position, m Move) bool { return isQuiescent(m) && l.isLegal(pos, m) })
+}
+
+// isQuiescent reports whether m is a capture or queen promotion.
+func isQuiescent(m Move) bool { return m.PromotePiece == Queen || (m.IsCapture() && !m.IsPromotion()) }
+
+// filter removes the Moves in ml for which keep returns false, preserving the order of the rest.
+func (ml *MoveList) filter(pos Position, keep func(Position, Move) bool) {
+	n := 0
+	for _, m := range ml.Moves() {
+		if keep(pos, m) {
+			ml.moves[n] = m
+			n++
+		}
+	}
+	ml.n = n
+}
+
+// sort sorts the Moves in ml into the order winning captures, equal captures, losing captures, non-captures.
+// (This terminology anticipates that the captured piece is defended and the capturing piece is liable to be captured in exchange.)
+func (ml *MoveList) sort() {
 	const (
 		winning = iota
 		equal
@@ -191,59 +262,140 @@ func PseudoLegalMoves(pos Position) []Move {
 			return losing
 		}
 	}
-	bins := make([]int, 4)
-	for _, m := range moves {
-		bins[moveType(m)]++
+	// Counting sort
+	var count, index [4]int
+	for _, m := range ml.Moves() {
+		count[moveType(m)]++
 	}
-	index := make([]int, len(bins))
-	for i := range index {
-		for j := 0; j < i; j++ {
-			index[i] += bins[j]
-		}
+	for i := 1; i < len(index); i++ {
+		index[i] = index[i-1] + count[i-1]
 	}
-	sorted := make([]Move, len(moves))
-	for _, m := range moves {
+	moves := ml.moves
+	for _, m := range moves[:ml.n] {
 		mt := moveType(m)
-		sorted[index[mt]] = m
+		ml.moves[index[mt]] = m
 		index[mt]++
 	}
-	return sorted
+}
+
+// PseudoLegalMoves returns all pseudo-legal Moves in pos.
+func PseudoLegalMoves(pos Position) []Move {
+	var ml MoveList
+	GenPseudoLegalMoves(pos, &ml)
+	return ml.slice()
+}
+
+// PseudoLegalCaptures returns the pseudo-legal captures and queen promotions in pos,
+// in the order in which PseudoLegalMoves returns them.
+func PseudoLegalCaptures(pos Position) []Move {
+	var ml MoveList
+	GenPseudoLegalMoves(pos, &ml)
+	ml.filter(pos, func(_ Position, m Move) bool { return isQuiescent(m) })
+	return ml.slice()
 }
 
 // LegalMoves returns all legal Moves in pos.
 func LegalMoves(pos Position) []Move {
-	pl := PseudoLegalMoves(pos)
-	legal := make([]Move, 0, len(pl))
-	for _, m := range pl {
-		if !IsLegal(Make(pos, m)) {
-			continue
-		}
-		legal = append(legal, m)
-	}
-	return legal
+	var ml MoveList
+	GenLegalMoves(pos, &ml)
+	return ml.slice()
 }
 
+// slice returns a newly allocated copy of the Moves in ml.
+func (ml *MoveList) slice() []Move { return append(make([]Move, 0, ml.n), ml.Moves()...) }
+
 // hasLegalMove reports whether pos has at least one legal move.
 func hasLegalMove(pos Position) bool {
-	// Generate the pseudolegal moves of each type of piece.
-	// If one of them is legal, return immediately.
-	for _, pl := range [][]Move{
-		PawnMoves(pos),
-		KnightMoves(pos),
-		BishopMoves(pos),
-		RookMoves(pos),
-		QueenMoves(pos),
-		KingMoves(pos),
-	} {
-		for _, m := range pl {
-			if IsLegal(Make(pos, m)) {
-				return true
-			}
+	var ml MoveList
+	genKingMoves(pos, &ml)
+	genPieceMoves(pos, Knight, &ml)
+	genPawnMoves(pos, &ml)
+	genPieceMoves(pos, Bishop, &ml)
+	genPieceMoves(pos, Rook, &ml)
+	genPieceMoves(pos, Queen, &ml)
+	l := newLegality(pos)
+	for _, m := range ml.Moves() {
+		if l.isLegal(pos, m) {
+			return true
 		}
 	}
 	return false
 }
 
+// A legality holds the information needed to determine whether pseudo-legal moves
+// in a Position leave the king of the side to move in check.
+type legality struct {
+	checkers  Board // the pieces giving check
+	checkMask Board // the squares to which a piece other than the king may move
+	pinned    Board // the pieces of the side to move that are pinned to their king
+}
+
+// newLegality computes the checking pieces, check mask, and pinned pieces of pos.
+func newLegality(pos Position) legality {
+	k := pos.KingSquare[pos.ToMove]
+	occ := pos.b[White][All] | pos.b[Black][All]
+	l := legality{checkers: attackers(pos, k, pos.Opp(), occ), checkMask: ^Board(0)}
+	switch PopCount(l.checkers) {
+	case 0:
+	case 1:
+		// Capture the checking piece or interpose.
+		l.checkMask = l.checkers | between[k][LS1BIndex(l.checkers)]
+	default:
+		// Only the king can move.
+		l.checkMask = 0
+	}
+	// A piece is pinned if it is the only piece between its king and an enemy slider on the same line.
+	opp := pos.b[pos.Opp()]
+	snipers := rookAttacks(k.Board(), ^Board(0))&(opp[Rook]|opp[Queen]) | bishopAttacks(k.Board(), ^Board(0))&(opp[Bishop]|opp[Queen])
+	rangeBits(snipers, func(_ Board, s Square) {
+		if b := between[k][s] & occ; PopCount(b) == 1 {
+			l.pinned |= b & pos.b[pos.ToMove][All]
+		}
+	})
+	return l
+}
+
+// isLegal reports whether the pseudo-legal Move m in pos is legal.
+func (l legality) isLegal(pos Position, m Move) bool {
+	k := pos.KingSquare[pos.ToMove]
+	switch {
+	case m.Piece == King:
+		if _, ok := m.IsCastle(); ok {
+			// canCastle has already determined that the king does not pass through check.
+			return true
+		}
+		// The king must not move along the line of a slider that checks it.
+		occ := (pos.b[White][All] | pos.b[Black][All]) &^ k.Board()
+		return attackers(pos, m.To, pos.Opp(), occ) == 0
+	case m.EP:
+		// En passant captures remove two pieces from a rank, which may expose the king; try the move.
+		return IsLegal(Make(pos, m))
+	case m.To.Board()&l.checkMask == 0:
+		return false
+	case m.From.Board()&l.pinned != 0:
+		// A pinned piece may move only along the line of the pin.
+		return line[k][m.From]&m.To.Board() != 0
+	}
+	return true
+}
+
+// attackers returns a Board of the pieces of Color c that attack s in pos when the occupied squares are occ.
+func attackers(pos Position, s Square, c Color, occ Board) Board {
+	b, empty := s.Board(), ^occ
+	var pawns Board
+	switch c {
+	case White:
+		pawns = blackPawnAttacks(b, empty) & pos.b[c][Pawn]
+	case Black:
+		pawns = whitePawnAttacks(b, empty) & pos.b[c][Pawn]
+	}
+	return pawns |
+		knightAttacks(b, empty)&pos.b[c][Knight] |
+		bishopAttacks(b, empty)&(pos.b[c][Bishop]|pos.b[c][Queen]) |
+		rookAttacks(b, empty)&(pos.b[c][Rook]|pos.b[c][Queen]) |
+		kingAttacks(b, empty)&pos.b[c][King]
+}
+
 // rangeBits applies f sequentially to each set bit in board.
 func rangeBits(board Board, f func(Board, Square)) {
 	for bits := board; bits != 0; bits = ResetLS1B(bits) {
@@ -254,7 +406,39 @@ func rangeBits(board Board, f func(Board, Square)) {
 
 // PawnMoves returns a slice of all pseudo-legal Moves that pawns can make in pos.
 func PawnMoves(pos Position) []Move {
-	moves := make([]Move, 0, 8*2*4) // cap: all pawns are on the 7th rank and can promote via capture to either side
+	var ml MoveList
+	genPawnMoves(pos, &ml)
+	return ml.slice()
+}
+
+// KnightMoves returns a slice of all pseudo-legal Moves that knights can make in pos.
+func KnightMoves(pos Position) []Move { return pMoves(pos, Knight) }
+
+// BishopMoves returns a slice of all pseudo-legal Moves that bishops can make in pos.
+func BishopMoves(pos Position) []Move { return pMoves(pos, Bishop) }
+
+// RookMoves returns a slice of all pseudo-legal Moves that rooks can make in pos.
+func RookMoves(pos Position) []Move { return pMoves(pos, Rook) }
+
+// QueenMoves returns a slice of all pseudo-legal Moves that queens can make in pos.
+func QueenMoves(pos Position) []Move { return pMoves(pos, Queen) }
+
+// KingMoves returns a slice of all pseudo-legal Moves that the king can make in pos.
+func KingMoves(pos Position) []Move {
+	var ml MoveList
+	genKingMoves(pos, &ml)
+	return ml.slice()
+}
+
+// pMoves returns a slice of all pseudo-legal Moves that non-pawn pieces of type p can make in pos, excluding castling.
+func pMoves(pos Position, p Piece) []Move {
+	var ml MoveList
+	genPieceMoves(pos, p, &ml)
+	return ml.slice()
+}
+
+// genPawnMoves appends all pseudo-legal Moves that pawns can make in pos to ml.
+func genPawnMoves(pos Position, ml *MoveList) {
 	empty := ^pos.b[White][All] & ^pos.b[Black][All]
 
 	// Pawn movesets are asymmetrical and their capture and non-capture movesets are disjoint
@@ -269,22 +453,22 @@ func PawnMoves(pos Position) []Move {
 	rangeBits(pos.b[pos.ToMove][Pawn], func(f Board, from Square) {
 		rangeBits(pawnAdv(f, empty), func(_ Board, to Square) {
 			if to.Rank() == promoteRank {
-				for _, pp := range []Piece{Queen, Rook, Bishop, Knight} {
-					moves = append(moves, Move{From: from, To: to, Piece: Pawn, PromotePiece: pp})
+				for _, pp := range [...]Piece{Queen, Rook, Bishop, Knight} {
+					ml.add(Move{From: from, To: to, Piece: Pawn, PromotePiece: pp})
 				}
 				return
 			}
-			moves = append(moves, Move{From: from, To: to, Piece: Pawn})
+			ml.add(Move{From: from, To: to, Piece: Pawn})
 		})
 		rangeBits(pawnAtk(f, empty)&pos.b[pos.Opp()][All], func(_ Board, to Square) {
 			_, cp := pos.PieceOn(to)
 			if to.Rank() == promoteRank {
-				for _, pp := range []Piece{Queen, Rook, Bishop, Knight} {
-					moves = append(moves, Move{From: from, To: to, Piece: Pawn, CapturePiece: cp, PromotePiece: pp})
+				for _, pp := range [...]Piece{Queen, Rook, Bishop, Knight} {
+					ml.add(Move{From: from, To: to, Piece: Pawn, CapturePiece: cp, PromotePiece: pp})
 				}
 				return
 			}
-			moves = append(moves, Move{From: from, To: to, Piece: Pawn, CapturePiece: cp})
+			ml.add(Move{From: from, To: to, Piece: Pawn, CapturePiece: cp})
 		})
 	})
 	if pos.ep != 0 {
@@ -292,35 +476,19 @@ func PawnMoves(pos Position) []Move {
 		epcs := pos.ep ^ 8
 		epSources := west(epcs.Board()) | east(epcs.Board())
 		rangeBits(epSources&pos.b[pos.ToMove][Pawn], func(_ Board, s Square) {
-			moves = append(moves, Move{From: s, To: pos.ep, Piece: Pawn, CapturePiece: Pawn, EP: true})
+			ml.add(Move{From: s, To: pos.ep, Piece: Pawn, CapturePiece: Pawn, EP: true})
 		})
 	}
-	return moves
 }
 
-// KnightMoves returns a slice of all pseudo-legal Moves that knights can make in pos.
-func KnightMoves(pos Position) []Move { return pMoves(pos, Knight) }
-
-// BishopMoves returns a slice of all pseudo-legal Moves that bishops can make in pos.
-func BishopMoves(pos Position) []Move { return pMoves(pos, Bishop) }
-
-// RookMoves returns a slice of all pseudo-legal Moves that rooks can make in pos.
-func RookMoves(pos Position) []Move { return pMoves(pos, Rook) }
-
-// QueenMoves returns a slice of all pseudo-legal Moves that queens can make in pos.
-func QueenMoves(pos Position) []Move { return pMoves(pos, Queen) }
-
-// KingMoves returns a slice of all pseudo-legal Moves that the king can make in pos.
-func KingMoves(pos Position) []Move {
-	moves := pMoves(pos, King)
-	from := pos.KingSquare[pos.ToMove]
-	if canCastle(pos, QS) {
-		moves = append(moves, Move{From: from, To: from - 2, Piece: King})
-	}
-	if canCastle(pos, KS) {
-		moves = append(moves, Move{From: from, To: from + 2, Piece: King})
+// genKingMoves appends all pseudo-legal Moves that the king can make in pos to ml.
+func genKingMoves(pos Position, ml *MoveList) {
+	genPieceMoves(pos, King, ml)
+	for _, side := range [...]Side{QS, KS} {
+		if canCastle(pos, side) {
+			ml.add(castleMove(pos, side))
+		}
 	}
-	return moves
 }
 
 // canCastle returns whether castling to side is legal in pos.
@@ -328,23 +496,57 @@ func canCastle(pos Position, side Side) bool {
 	if !pos.Castle[pos.ToMove][side] {
 		return false
 	}
-	empty := ^pos.b[White][All] & ^pos.b[Black][All]
-	if CastleEmptySquares[pos.ToMove][side]&^empty != 0 {
+	kingFrom, kingTo, rookFrom, rookTo := castleSquares(pos, side)
+	if pos.b[pos.ToMove][Rook]&rookFrom.Board() == 0 {
+		return false
+	}
+	// All squares traversed by the king and rook must be empty except for the king and rook themselves.
+	occ := (pos.b[White][All] | pos.b[Black][All]) &^ kingFrom.Board() &^ rookFrom.Board()
+	kingPath := between[kingFrom][kingTo] | kingFrom.Board() | kingTo.Board()
+	if (kingPath|between[rookFrom][rookTo]|rookTo.Board())&occ != 0 {
 		return false
 	}
-	var attacked bool
-	rangeBits(CastleKingSquares[pos.ToMove][side], func(_ Board, s Square) {
-		if attacked {
-			return
+	// The king may not castle out of, through, or into check.
+	// The castling rook is removed first, since in Chess960 it may shield the king's destination.
+	for b := kingPath; b != 0; b = ResetLS1B(b) {
+		if attackers(pos, LS1BIndex(b), pos.Opp(), occ) != 0 {
+			return false
 		}
-		attacked = IsAttacked(pos, s, pos.Opp())
-	})
-	return !attacked
+	}
+	return true
 }
 
-// pMoves returns a slice of all pseudo-legal Moves that non-pawn pieces of type p can make in pos, excluding castling.
-func pMoves(pos Position, p Piece) []Move {
-	moves := make([]Move, 0, 28) // two bishops, two rooks, or one queen can have 28 moves
+// castleMove returns the Move by which the side to move in pos castles to side.
+func castleMove(pos Position, side Side) Move {
+	kingFrom, kingTo, rookFrom, _ := castleSquares(pos, side)
+	if pos.Chess960 {
+		return Move{From: kingFrom, To: rookFrom, Piece: King, Castle960: true}
+	}
+	return Move{From: kingFrom, To: kingTo, Piece: King}
+}
+
+// castleSquares returns the origin and destination Squares of the king and rook
+// when the side to move in pos castles to side.
+func castleSquares(pos Position, side Side) (kingFrom, kingTo, rookFrom, rookTo Square) {
+	rank := 56 * Square(pos.ToMove)
+	kingFrom, rookFrom = pos.KingSquare[pos.ToMove], pos.castleRook(pos.ToMove, side)
+	if side == QS {
+		return kingFrom, rank + 2, rookFrom, rank + 3
+	}
+	return kingFrom, rank + 6, rookFrom, rank + 5
+}
+
+// castleRook returns the original Square of the rook with which c may castle to side in pos.
+func (pos Position) castleRook(c Color, side Side) Square {
+	file := [...]byte{0, 7}[side]
+	if pos.Chess960 {
+		file = pos.CastleFile[c][side]
+	}
+	return 56*Square(c) + Square(file)
+}
+
+// genPieceMoves appends all pseudo-legal Moves that non-pawn pieces of type p can make in pos to ml, excluding castling.
+func genPieceMoves(pos Position, p Piece, ml *MoveList) {
 	empty := ^pos.b[White][All] & ^pos.b[Black][All]
 	var pAttacks func(Board, Board) Board
 	switch p {
@@ -366,15 +568,19 @@ func pMoves(pos Position, p Piece) []Move {
 				_, capturePiece := pos.PieceOn(to)
 				m.CapturePiece = capturePiece
 			}
-			moves = append(moves, m)
+			ml.add(m)
 		})
 	})
-	return moves
 }
 
 var (
 	kAttacks = make([]Board, 64)
 	nAttacks = make([]Board, 64)
+
+	// between holds the squares strictly between two squares on the same rank, file, or diagonal.
+	between [64][64]Board
+	// line holds all squares on the rank, file, or diagonal containing two squares.
+	line [64][64]Board
 )
 
 func init() {
@@ -390,6 +596,16 @@ func init() {
 		// -10         -6
 		//    -17  -15
 		nAttacks[s] = southwest(south(b)) | southeast(south(b)) | southwest(west(b)) | southeast(east(b)) | northwest(west(b)) | northeast(east(b)) | northwest(north(b)) | northeast(north(b))
+
+		for t := a1; t <= h8; t++ {
+			tb := t.Board()
+			for _, attacks := range []func(Board, Board) Board{slowBishopAttacks, slowRookAttacks} {
+				if s != t && attacks(b, ^Board(0))&tb != 0 {
+					between[s][t] = attacks(b, ^tb) & attacks(tb, ^b)
+					line[s][t] = attacks(b, ^Board(0))&attacks(tb, ^Board(0)) | b | tb
+				}
+			}
+		}
 	}
 }
 
@@ -414,11 +630,25 @@ func knightAttacks(p, _ Board) Board { return nAttacks[LS1BIndex(p)] }
 
 // bishopAttacks returns a Board of all squares attacked by a bishop at p when there are no pieces at empty.
 func bishopAttacks(p, empty Board) Board {
-	return attackFill(p, empty, southwest) | attackFill(p, empty, southeast) | attackFill(p, empty, northwest) | attackFill(p, empty, northeast)
+	m := &bishopMagics[LS1BIndex(p)]
+	return m.attacks[m.index(^empty)]
 }
 
 // rookAttacks returns a Board of all squares attacked by a rook at p when there are no pieces at empty.
 func rookAttacks(p, empty Board) Board {
+	m := &rookMagics[LS1BIndex(p)]
+	return m.attacks[m.index(^empty)]
+}
+
+// slowBishopAttacks returns a Board of all squares attacked by bishops at p when there are no pieces at empty.
+// It is used to construct the tables for bishopAttacks.
+func slowBishopAttacks(p, empty Board) Board {
+	return attackFill(p, empty, southwest) | attackFill(p, empty, southeast) | attackFill(p, empty, northwest) | attackFill(p, empty, northeast)
+}
+
+// slowRookAttacks returns a Board of all squares attacked by rooks at p when there are no pieces at empty.
+// It is used to construct the tables for rookAttacks.
+func slowRookAttacks(p, empty Board) Board {
 	return attackFill(p, empty, south) | attackFill(p, empty, west) | attackFill(p, empty, east) | attackFill(p, empty, north)
 }
 
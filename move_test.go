@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
 
 const aroundD4 = (CFile|DFile|EFile)&(Rank3|Rank4|Rank5) ^ (DFile & Rank4)
 
@@ -221,6 +225,22 @@ func TestPieceAttacks(t *testing.T) {
 	}
 }
 
+func TestMagicAttacks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for s := a1; s <= h8; s++ {
+		b := s.Board()
+		for i := 0; i < 100; i++ {
+			empty := ^Board(r.Uint64()&r.Uint64()) | b
+			if got, want := bishopAttacks(b, empty), slowBishopAttacks(b, empty); got != want {
+				t.Errorf("bishopAttacks(%v, %016x): got %016x, want %016x", s, empty, got, want)
+			}
+			if got, want := rookAttacks(b, empty), slowRookAttacks(b, empty); got != want {
+				t.Errorf("rookAttacks(%v, %016x): got %016x, want %016x", s, empty, got, want)
+			}
+		}
+	}
+}
+
 func TestIsAttacked(t *testing.T) {
 	for _, test := range []struct {
 		pos  Position
@@ -394,3 +414,113 @@ func BenchmarkPseudoLegalMoves(b *testing.B) {
 		PseudoLegalMoves(pos)
 	}
 }
+
+func BenchmarkGenLegalMoves(b *testing.B) {
+	pos, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var ml MoveList
+	for i := 0; i < b.N; i++ {
+		GenLegalMoves(pos, &ml)
+	}
+}
+
+func TestGenLegalMoves(t *testing.T) {
+	for _, fen := range []string{
+		InitialPositionFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"4k3/8/8/8/8/8/3q4/4K3 w - - 0 1",           // the king must capture or leave the line of the queen
+		"4k3/8/8/1b6/8/8/4PN2/4K2R w K - 0 1",       // the pawn can interpose, but the knight cannot
+		"4k3/4r3/8/8/1b6/8/3N4/4K3 w - - 0 1",       // double check
+		"4k3/4r3/8/8/8/8/4R3/4K3 w - - 0 1",         // the rook is pinned along the file
+		"4k3/8/8/7b/8/8/4P3/3K4 w - - 0 1",          // the pawn is pinned against a diagonal
+		"8/8/8/KPp4r/8/8/8/4k3 w - c6 0 2",          // en passant exposes the king along the rank
+		"8/8/8/8/k2Pp2Q/8/8/4K3 b - d3 0 1",         // en passant exposes the king along the rank
+		"4k3/8/8/2KPp3/8/8/8/8 w - e6 0 2",          // en passant captures the checking pawn
+		"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",      // castling
+		"4k3/8/8/8/8/8/3p4/4K3 w - - 0 1",           // the king is in check from a pawn
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",   // promotions
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", // pins and en passant
+	} {
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []Move
+		for _, m := range PseudoLegalMoves(pos) {
+			if IsLegal(Make(pos, m)) {
+				want = append(want, m)
+			}
+		}
+		var ml MoveList
+		GenLegalMoves(pos, &ml)
+		if got := ml.Moves(); !reflect.DeepEqual(got, want) {
+			t.Errorf("GenLegalMoves(%v): got %v, want %v", fen, got, want)
+		}
+		if got, want := hasLegalMove(pos), len(want) > 0; got != want {
+			t.Errorf("hasLegalMove(%v): got %v, want %v", fen, got, want)
+		}
+	}
+}
+
+func TestGenLegalMovesAllocs(t *testing.T) {
+	pos, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ml MoveList
+	if n := testing.AllocsPerRun(100, func() { GenLegalMoves(pos, &ml) }); n != 0 {
+		t.Errorf("GenLegalMoves: got %v allocations, want 0", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { hasLegalMove(pos) }); n != 0 {
+		t.Errorf("hasLegalMove: got %v allocations, want 0", n)
+	}
+}
+
+func TestPseudoLegalCaptures(t *testing.T) {
+	for _, test := range []struct {
+		fen  string
+		want int
+	}{
+		{InitialPositionFEN, 0},
+		{"4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", 1},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 2", 1}, // en passant
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", 2},  // promotions to queen only
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 8},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := PseudoLegalCaptures(pos)
+		if len(got) != test.want {
+			t.Errorf("PseudoLegalCaptures(%v): got %v moves, want %v", test.fen, len(got), test.want)
+		}
+		for _, m := range got {
+			if !m.IsCapture() && m.PromotePiece != Queen {
+				t.Errorf("PseudoLegalCaptures(%v): got non-capture %+v", test.fen, m)
+			}
+		}
+	}
+}
+
+func TestMakeNull(t *testing.T) {
+	for _, test := range []struct{ fen, want string }{
+		{InitialPositionFEN, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1"},
+		{"rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 3", "rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 4"},
+		{"4k3/8/8/8/8/8/8/4K2R w K - 12 40", "4k3/8/8/8/8/8/8/4K2R b K - 0 40"},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := MakeNull(pos)
+		if fen := FEN(got); fen != test.want {
+			t.Errorf("MakeNull(%v): got %v, want %v", test.fen, fen, test.want)
+		}
+		if got.z != got.Zobrist() {
+			t.Errorf("MakeNull(%v): got Zobrist %x, want %x", test.fen, got.z, got.Zobrist())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+// Moves are searched in stages, in decreasing order of these ordering scores:
+// the hash move, then captures and promotions that do not lose material, then killer moves,
+// then losing captures, then other moves by history score.
+const (
+	hashMoveOrder      = 1 << 30
+	captureOrder       = 1 << 29
+	killerOrder        = 1 << 28
+	losingCaptureOrder = 1 << 27
+
+	// maxHistory bounds the history scores, which are halved when one exceeds it.
+	maxHistory = 1 << 20
+)
+
+// mvvLVA returns the ordering score of a capture or promotion among the others:
+// most valuable victim first, and then least valuable attacker.
+func mvvLVA(m Move) int { return 8*(int(m.CapturePiece)+int(m.PromotePiece)) - int(m.Piece) }
+
+// isQuiet reports whether m is neither a capture nor a promotion.
+func isQuiet(m Move) bool { return !m.IsCapture() && !m.IsPromotion() }
+
+// orderScore returns the ordering score of m in pos at the current ply with the given hash move.
+func (s *Search) orderScore(pos Position, m, hashMove Move) int {
+	switch {
+	case m == hashMove:
+		return hashMoveOrder
+	case !isQuiet(m):
+		if see := SEE(pos, m); see < 0 {
+			return losingCaptureOrder + see
+		}
+		return captureOrder + mvvLVA(m)
+	}
+	if s.ply < len(s.killers) {
+		for i, k := range s.killers[s.ply] {
+			if m == k {
+				return killerOrder - i
+			}
+		}
+	}
+	return s.history[pos.ToMove][m.From][m.To]
+}
+
+// orderMoves sorts rs, the Results of the legal moves of pos, into the order in which they should be searched.
+func (s *Search) orderMoves(pos Position, rs Results, hashMove Move) {
+	if !s.ordering {
+		// Search the hash move first and the others in the order generated.
+		for i := range rs {
+			if rs[i].move == hashMove {
+				rs[0], rs[i] = rs[i], rs[0]
+				break
+			}
+		}
+		return
+	}
+	var keys [maxMoves]int
+	for i, r := range rs {
+		keys[i] = s.orderScore(pos, r.move, hashMove)
+	}
+	// Insertion sort is stable and fast for the short lists.
+	for i := 1; i < len(rs); i++ {
+		r, k := rs[i], keys[i]
+		j := i
+		for ; j > 0 && keys[j-1] < k; j-- {
+			rs[j], keys[j] = rs[j-1], keys[j-1]
+		}
+		rs[j], keys[j] = r, k
+	}
+}
+
+// orderCaptures sorts the captures and promotions in ml by MVV-LVA.
+func orderCaptures(ml *MoveList) {
+	moves := ml.Moves()
+	for i := 1; i < len(moves); i++ {
+		m := moves[i]
+		j := i
+		for ; j > 0 && mvvLVA(moves[j-1]) < mvvLVA(m); j-- {
+			moves[j] = moves[j-1]
+		}
+		moves[j] = m
+	}
+}
+
+// cutoff updates the killer moves and history scores for a move by c that caused a beta cutoff
+// in a search to the specified depth.
+func (s *Search) cutoff(c Color, m Move, depth int) {
+	if !isQuiet(m) {
+		return
+	}
+	for len(s.killers) <= s.ply {
+		s.killers = append(s.killers, [2]Move{})
+	}
+	if k := &s.killers[s.ply]; k[0] != m {
+		k[0], k[1] = m, k[0]
+	}
+	h := &s.history[c][m.From][m.To]
+	if *h += depth * depth; *h > maxHistory {
+		for c := range s.history {
+			for from := range s.history[c] {
+				for to := range s.history[c][from] {
+					s.history[c][from][to] /= 2
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// searchNodes returns the number of nodes searched by iterative deepening of pos to the specified depth
+// without a transposition table.
+func searchNodes(pos Position, depth int, ordering bool) int {
+	s := newSearch(nil, depth)
+	s.tt, s.ordering = nil, ordering
+	var rs Results
+	for d := 1; d <= depth; d++ {
+		_, rs = s.negamax(context.Background(), pos, rs, openWindow, d)
+	}
+	return s.info(depth).Nodes
+}
+
+func TestOrderingNodes(t *testing.T) {
+	for _, fen := range []string{
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"r2q1rk1/ppp2ppp/2np1n2/2b1p1B1/2B1P1b1/2NP1N2/PPP2PPP/R2Q1RK1 w - - 6 8",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	} {
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		without, with := searchNodes(pos, 4, false), searchNodes(pos, 4, true)
+		t.Logf("%v: %v nodes without ordering, %v with", fen, without, with)
+		if with >= without {
+			t.Errorf("%v: ordering searched %v nodes, want fewer than %v", fen, with, without)
+		}
+	}
+}
+
+func TestOrderMoves(t *testing.T) {
+	pos, err := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashMove := Move{From: e1, To: g1, Piece: King}
+	killer := Move{From: a2, To: a3, Piece: Pawn}
+	history := Move{From: g2, To: g3, Piece: Pawn}
+	s := newSearch(nil, 1)
+	s.killers = [][2]Move{{killer}}
+	s.history[White][g2][g3] = 100
+
+	rs := legalResults(pos)
+	s.orderMoves(pos, rs, hashMove)
+	if rs[0].move != hashMove {
+		t.Errorf("got first move %v, want hash move %v", rs[0].move, hashMove)
+	}
+	if want := (Move{From: e2, To: a6, Piece: Bishop, CapturePiece: Bishop}); rs[1].move != want {
+		t.Errorf("got second move %v, want %v", rs[1].move, want)
+	}
+	var captures int
+	for i, r := range rs[1:] {
+		if isQuiet(r.move) {
+			captures = i
+			break
+		}
+		if SEE(pos, r.move) < 0 {
+			t.Errorf("losing capture %v sorts before the killer", LongAlgebraic(r.move))
+		}
+		if i > 0 && mvvLVA(r.move) > mvvLVA(rs[i].move) {
+			t.Errorf("capture %v sorts after %v", LongAlgebraic(r.move), LongAlgebraic(rs[i].move))
+		}
+	}
+	if got := rs[1+captures].move; got != killer {
+		t.Errorf("got first quiet move %v, want killer %v", got, killer)
+	}
+	// Losing captures follow the killers.
+	i := 2 + captures
+	for ; !isQuiet(rs[i].move); i++ {
+		if see := SEE(pos, rs[i].move); see >= 0 {
+			t.Errorf("capture %v with SEE %v sorts after the killer", LongAlgebraic(rs[i].move), see)
+		}
+	}
+	if i == 2+captures {
+		t.Errorf("got no losing captures, want Nxg6")
+	}
+	if got := rs[i].move; got != history {
+		t.Errorf("got second quiet move %v, want %v", got, history)
+	}
+}
+
+func TestCutoff(t *testing.T) {
+	s := newSearch(nil, 1)
+	s.ply = 2
+	m1, m2 := Move{From: a2, To: a3, Piece: Pawn}, Move{From: b1, To: c3, Piece: Knight}
+	s.cutoff(White, m1, 3)
+	s.cutoff(White, m2, 2)
+	s.cutoff(White, m2, 2)
+	s.cutoff(White, Move{From: d1, To: d8, Piece: Queen, CapturePiece: Queen}, 5)
+	if got := s.killers[2]; got != [2]Move{m2, m1} {
+		t.Errorf("got killers %v, want %v", got, [2]Move{m2, m1})
+	}
+	if got := s.history[White][a2][a3]; got != 9 {
+		t.Errorf("got history %v, want 9", got)
+	}
+	if got := s.history[White][b1][c3]; got != 8 {
+		t.Errorf("got history %v, want 8", got)
+	}
+	if got := s.history[White][d1][d8]; got != 0 {
+		t.Errorf("got history %v for a capture, want 0", got)
+	}
+}
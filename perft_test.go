@@ -140,6 +140,14 @@ func TestPerft(t *testing.T) {
 		{"n1n5/1Pk5/8/8/8/8/5Kp1/5N1N b - - 0 1", []int{24, 421, 7421, 124608, 2193768, 37665329}},
 		{"8/PPPk4/8/8/8/8/4Kppp/8 b - - 0 1", []int{18, 270, 4699, 79355, 1533145, 28859283}},
 		{"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1", []int{24, 496, 9483, 182838, 3605103, 71179139}},
+
+		// Chess960 positions
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", []int{21, 528, 12189, 326672, 8146062}},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", []int{21, 807, 18002, 667366}},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", []int{20, 479, 10471, 273318}},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", []int{22, 593, 13440, 382958}},
+		{"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", []int{28, 1120, 31058, 1171749}},
+		{"qnbnr1kr/ppp1b1pp/4p3/3p1p2/8/2NPP3/PPP1BPPP/QNB1R1KR w HEhe - 1 9", []int{29, 899, 26578, 824055}},
 	} {
 		log.Println(i, test.fen)
 		pos, err := ParseFEN(test.fen)
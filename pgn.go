@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// sevenTagRoster lists the tags that every PGN game contains, in the order in which they are written.
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// pgnLineLength is the maximum length of a line of PGN movetext.
+const pgnLineLength = 79
+
+// suffixNAGs maps move suffix annotations to their equivalent Numeric Annotation Glyphs.
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// Game is a chess game in the form of a tree of moves and their annotations.
+type Game struct {
+	// Tags holds the game's tag pairs.
+	Tags map[string]string
+
+	// Root holds the starting Position. Its main line is the game's sequence of moves.
+	Root *GameNode
+}
+
+// GameNode is a Move in a Game, together with its annotations and continuations.
+type GameNode struct {
+	Move Move
+
+	// Eval, if not nil, is an evaluation of the Position after Move, written as a comment.
+	Eval *Abs
+
+	// Comments and NAGs hold the commentary and Numeric Annotation Glyphs following Move.
+	Comments []string
+	NAGs     []int
+
+	// Parent is the GameNode preceding Move, or nil for the root of a Game.
+	Parent *GameNode
+
+	// Children holds the continuations after Move. Children[0] continues the line,
+	// and any other elements are variations, alternatives to Children[0].
+	Children []*GameNode
+
+	// pos is the Position after Move.
+	pos Position
+}
+
+// NewGame returns a Game beginning at start in which moves were played and that ended with result.
+// evals, if not nil, holds the evaluations of the Positions after each Move.
+func NewGame(start Position, moves []Move, evals []Abs, result string) *Game {
+	g := &Game{
+		Tags: map[string]string{"Result": result},
+		Root: &GameNode{pos: start},
+	}
+	n := g.Root
+	for i, m := range moves {
+		n = n.add(m)
+		if evals != nil {
+			n.Eval = &evals[i]
+		}
+	}
+	return g
+}
+
+// add appends a continuation to n and returns it. m must be legal in n's Position.
+func (n *GameNode) add(m Move) *GameNode {
+	c := &GameNode{Move: m, Parent: n, pos: Make(n.pos, m)}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// Position returns the Position after n's Move.
+func (n *GameNode) Position() Position { return n.pos }
+
+// Start returns the starting Position of g.
+func (g *Game) Start() Position { return g.Root.pos }
+
+// MainLine returns the Moves of g's main line.
+func (g *Game) MainLine() []Move {
+	var moves []Move
+	for n := g.Root; len(n.Children) > 0; {
+		n = n.Children[0]
+		moves = append(moves, n.Move)
+	}
+	return moves
+}
+
+// Result returns the result of g: "1-0", "0-1", "1/2-1/2", or "*" if it is unknown.
+func (g *Game) Result() string {
+	switch r := g.Tags["Result"]; r {
+	case "1-0", "0-1", "1/2-1/2":
+		return r
+	}
+	return "*"
+}
+
+// WritePGN writes g to w in PGN export format.
+func WritePGN(w io.Writer, g *Game) error {
+	bw := bufio.NewWriter(w)
+	for _, tag := range sevenTagRoster {
+		v, ok := g.Tags[tag]
+		switch {
+		case tag == "Result":
+			v = g.Result()
+		case !ok && tag == "Date":
+			v = "????.??.??"
+		case !ok:
+			v = "?"
+		}
+		writeTag(bw, tag, v)
+	}
+	var tags []string
+	for tag := range g.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		switch tag {
+		case "Event", "Site", "Date", "Round", "White", "Black", "Result", "SetUp", "FEN":
+			continue
+		}
+		writeTag(bw, tag, g.Tags[tag])
+	}
+	if fen := FEN(g.Start()); fen != InitialPositionFEN {
+		writeTag(bw, "SetUp", "1")
+		writeTag(bw, "FEN", fen)
+	}
+	fmt.Fprintln(bw)
+
+	mw := &movetextWriter{w: bw}
+	for _, c := range g.Root.Comments {
+		mw.token("{" + c + "}")
+	}
+	if len(g.Root.Children) > 0 {
+		mw.writeLine(g.Start(), g.Root.Children[0], g.Root.Children[1:], true)
+	}
+	mw.token(g.Result())
+	fmt.Fprintf(bw, "\n\n")
+	return bw.Flush()
+}
+
+// writeTag writes a tag pair.
+func writeTag(w io.Writer, tag, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	fmt.Fprintf(w, "[%v \"%v\"]\n", tag, value)
+}
+
+// movetextWriter writes movetext tokens separated by spaces, wrapping lines at pgnLineLength.
+type movetextWriter struct {
+	w *bufio.Writer
+
+	// n is the length of the current line.
+	n int
+
+	// open reports whether the previous token opened a variation.
+	open bool
+}
+
+// token writes s, preceded by a space or newline unless it begins the movetext,
+// follows the opening of a variation, or closes a variation.
+func (mw *movetextWriter) token(s string) {
+	switch {
+	case mw.n == 0:
+	case mw.n+1+len(s) > pgnLineLength:
+		mw.w.WriteByte('\n')
+		mw.n = 0
+	case !mw.open && s != ")":
+		mw.w.WriteByte(' ')
+		mw.n++
+	}
+	mw.w.WriteString(s)
+	mw.n += len(s)
+	mw.open = s == "("
+}
+
+// writeLine writes the Move of n, played from pos, followed by the variations that are alternatives to it
+// and then the line that continues from it. force reports whether to write the move number of a Black move.
+func (mw *movetextWriter) writeLine(pos Position, n *GameNode, variations []*GameNode, force bool) {
+	for {
+		if pos.ToMove == White || force {
+			mw.token(moveNumber(pos))
+		}
+		mw.token(pgnAlgebraic(pos, n.Move))
+		for _, nag := range n.NAGs {
+			mw.token("$" + strconv.Itoa(nag))
+		}
+		force = false
+		if n.Eval != nil {
+			mw.token(fmt.Sprintf("{%v}", *n.Eval))
+			force = true
+		}
+		for _, c := range n.Comments {
+			mw.token("{" + c + "}")
+			force = true
+		}
+		for _, v := range variations {
+			mw.token("(")
+			mw.writeLine(pos, v, nil, true)
+			mw.token(")")
+			force = true
+		}
+		if len(n.Children) == 0 {
+			return
+		}
+		pos = n.pos
+		n, variations = n.Children[0], n.Children[1:]
+	}
+}
+
+// pgnAlgebraic returns the description of m in standard algebraic notation as written in PGN,
+// which marks pawn promotion with an equals sign (e8=Q).
+func pgnAlgebraic(pos Position, m Move) string {
+	s := Algebraic(pos, m)
+	if m.IsPromotion() {
+		i := strings.Index(s, m.To.String()) + 2
+		s = s[:i] + "=" + s[i:]
+	}
+	return s
+}
+
+// PGNReader reads Games in PGN format.
+type PGNReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewPGNReader returns a PGNReader reading from r.
+func NewPGNReader(r io.Reader) *PGNReader {
+	return &PGNReader{r: bufio.NewReader(r), line: 1}
+}
+
+// pgnToken is a lexical element of PGN.
+type pgnToken struct {
+	kind rune // one of '[', '{', '(', ')', '$', or 's' for a symbol
+	s    string
+	tag  string // the name of a tag pair; s holds its value
+}
+
+// Read reads the next Game. At the end of the input, it returns io.EOF.
+// After a game that is not valid PGN, the rest of it is skipped, so that the following games may still be read.
+func (pr *PGNReader) Read() (*Game, error) {
+	g, err := pr.read()
+	if _, ok := err.(*pgnSyntaxError); ok {
+		pr.skipGame()
+	}
+	return g, err
+}
+
+// skipGame discards the input up to the next line that begins with a tag pair, which begins the following game.
+func (pr *PGNReader) skipGame() {
+	for {
+		if _, err := pr.readUntil('\n'); err != nil {
+			return
+		}
+		if b, err := pr.r.Peek(1); err != nil || b[0] == '[' {
+			return
+		}
+	}
+}
+
+// read reads the next Game.
+func (pr *PGNReader) read() (*Game, error) {
+	g := &Game{Tags: make(map[string]string)}
+	var (
+		n     *GameNode   // the current node
+		stack []*GameNode // the nodes from which variations branch
+		empty = true      // whether the Game so far contains no tokens
+	)
+	start := func() error {
+		pos := InitialPosition
+		if fen, ok := g.Tags["FEN"]; ok {
+			var err error
+			if pos, err = ParseFEN(fen); err != nil {
+				return err
+			}
+		}
+		if v := strings.ToLower(g.Tags["Variant"]); strings.Contains(v, "960") || v == "fischerandom" {
+			pos.Chess960 = true
+		}
+		g.Root = &GameNode{pos: pos}
+		n = g.Root
+		return nil
+	}
+	for {
+		t, err := pr.next()
+		if err == io.EOF && !empty {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		empty = false
+		if t.kind == '[' {
+			if n != nil {
+				return nil, pr.errorf("tag pair %v in movetext", t.tag)
+			}
+			g.Tags[t.tag] = t.s
+			continue
+		}
+		if n == nil {
+			if err := start(); err != nil {
+				return nil, pr.errorf("%v", err)
+			}
+		}
+		switch t.kind {
+		case '{':
+			n.Comments = append(n.Comments, t.s)
+		case '$':
+			nag, err := strconv.Atoi(t.s)
+			if err != nil {
+				return nil, pr.errorf("invalid NAG $%v", t.s)
+			}
+			n.NAGs = append(n.NAGs, nag)
+		case '(':
+			if n.Parent == nil {
+				return nil, pr.errorf("variation before first move")
+			}
+			stack = append(stack, n)
+			n = n.Parent
+		case ')':
+			if len(stack) == 0 {
+				return nil, pr.errorf("unmatched )")
+			}
+			n, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		case 's':
+			switch {
+			case t.s == "1-0" || t.s == "0-1" || t.s == "1/2-1/2" || t.s == "*":
+				if len(stack) > 0 {
+					return nil, pr.errorf("unterminated variation")
+				}
+				if _, ok := g.Tags["Result"]; !ok {
+					g.Tags["Result"] = t.s
+				}
+				return g, nil
+			default:
+				san := t.s
+				if i := strings.IndexFunc(san, func(r rune) bool { return !unicode.IsDigit(r) }); i > 0 && san[i] == '.' {
+					// Skip the move number indication.
+					if san = strings.TrimLeft(san[i:], "."); san == "" {
+						continue
+					}
+				}
+				san, suffix := splitSuffix(san)
+				m, err := ParseAlgebraic(n.pos, san)
+				if err != nil {
+					return nil, pr.errorf("%v", err)
+				}
+				n = n.add(m)
+				if suffix != "" {
+					nag, ok := suffixNAGs[suffix]
+					if !ok {
+						return nil, pr.errorf("invalid annotation %v", t.s)
+					}
+					n.NAGs = append(n.NAGs, nag)
+				}
+			}
+		}
+	}
+}
+
+// A pgnSyntaxError describes invalid PGN at a line of the input.
+type pgnSyntaxError struct {
+	line int
+	msg  string
+}
+
+func (e *pgnSyntaxError) Error() string { return fmt.Sprintf("pgn: line %v: %v", e.line, e.msg) }
+
+// errorf returns a pgnSyntaxError describing a problem at the current line of the input.
+func (pr *PGNReader) errorf(format string, a ...interface{}) error {
+	return &pgnSyntaxError{line: pr.line, msg: fmt.Sprintf(format, a...)}
+}
+
+// readRune reads a rune and counts lines.
+func (pr *PGNReader) readRune() (rune, error) {
+	r, _, err := pr.r.ReadRune()
+	if r == '\n' {
+		pr.line++
+	}
+	return r, err
+}
+
+// readUntil reads until the delimiter and returns the text read, without the delimiter.
+func (pr *PGNReader) readUntil(delim rune) (string, error) {
+	var b strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err != nil {
+			return "", err
+		}
+		if r == delim {
+			return b.String(), nil
+		}
+		b.WriteRune(r)
+	}
+}
+
+// next returns the next token.
+func (pr *PGNReader) next() (pgnToken, error) {
+	atLineStart := pr.line == 1
+	for {
+		r, err := pr.readRune()
+		if err != nil {
+			return pgnToken{}, err
+		}
+		switch {
+		case r == '\n':
+			atLineStart = true
+			continue
+		case unicode.IsSpace(r):
+			continue
+		case r == '%' && atLineStart, r == ';':
+			// escape or rest-of-line comment
+			if _, err := pr.readUntil('\n'); err != nil {
+				return pgnToken{}, err
+			}
+			atLineStart = true
+			continue
+		case r == '[':
+			return pr.tagPair()
+		case r == '{':
+			s, err := pr.readUntil('}')
+			if err != nil {
+				return pgnToken{}, unexpected(err)
+			}
+			return pgnToken{kind: '{', s: strings.TrimSpace(s)}, nil
+		case r == '(' || r == ')':
+			return pgnToken{kind: r}, nil
+		case r == '$':
+			return pgnToken{kind: '$', s: pr.symbol("")}, nil
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '*':
+			return pgnToken{kind: 's', s: pr.symbol(string(r))}, nil
+		default:
+			return pgnToken{}, pr.errorf("unexpected character %q", r)
+		}
+	}
+}
+
+// symbol reads the remainder of a symbol token beginning with prefix.
+func (pr *PGNReader) symbol(prefix string) string {
+	s := prefix
+	for {
+		r, _, err := pr.r.ReadRune()
+		if err != nil {
+			return s
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune("[]{}()$;\"", r) {
+			pr.r.UnreadRune()
+			return s
+		}
+		s += string(r)
+	}
+}
+
+// tagPair reads the remainder of a tag pair.
+func (pr *PGNReader) tagPair() (pgnToken, error) {
+	s, err := pr.readUntil('"')
+	if err != nil {
+		return pgnToken{}, unexpected(err)
+	}
+	tag := strings.TrimSpace(s)
+	if tag == "" || strings.IndexFunc(tag, unicode.IsSpace) != -1 {
+		return pgnToken{}, pr.errorf("invalid tag name %q", tag)
+	}
+	var value strings.Builder
+	for {
+		r, err := pr.readRune()
+		if err != nil {
+			return pgnToken{}, unexpected(err)
+		}
+		if r == '"' {
+			break
+		}
+		if r == '\\' {
+			if r, err = pr.readRune(); err != nil {
+				return pgnToken{}, unexpected(err)
+			}
+		}
+		value.WriteRune(r)
+	}
+	if s, err := pr.readUntil(']'); err != nil {
+		return pgnToken{}, unexpected(err)
+	} else if strings.TrimSpace(s) != "" {
+		return pgnToken{}, pr.errorf("invalid tag pair %v", tag)
+	}
+	return pgnToken{kind: '[', tag: tag, s: value.String()}, nil
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// splitSuffix separates a move from its suffix annotation, if any.
+func splitSuffix(s string) (san, suffix string) {
+	i := strings.IndexAny(s, "!?")
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
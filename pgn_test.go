@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWritePGN(t *testing.T) {
+	pos := InitialPosition
+	var moves []Move
+	for _, s := range []string{"e2e4", "e7e5", "g1f3", "b8c6"} {
+		m, err := ParseCoordinate(pos, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, m)
+		pos = Make(pos, m)
+	}
+	evals := []Abs{{n: 30}, {n: 25}, {n: 40}, {n: 35}}
+	g := NewGame(InitialPosition, moves, evals, "1/2-1/2")
+	g.Tags["White"] = "bandit"
+	g.Tags["Annotator"] = `Dan "the" Man`
+
+	var b bytes.Buffer
+	if err := WritePGN(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	want := `[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "bandit"]
+[Black "?"]
+[Result "1/2-1/2"]
+[Annotator "Dan \"the\" Man"]
+
+1. e4 {0.30} 1... e5 {0.25} 2. Nf3 {0.40} 2... Nc6 {0.35} 1/2-1/2
+
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestWritePGNSetUp(t *testing.T) {
+	fen := "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1"
+	pos, err := ParseFEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame(pos, []Move{{From: b7, To: b8, Piece: Pawn, PromotePiece: Queen}}, nil, "")
+	var b bytes.Buffer
+	if err := WritePGN(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[SetUp \"1\"]\n[FEN \"" + fen + "\"]\n", "\n1. b8=Q+ *\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got\n%v\nwant it to contain %q", b.String(), want)
+		}
+	}
+}
+
+const testPGN = `[Event "Casual game"]
+[Site "?"]
+[Date "1858.??.??"]
+[Round "?"]
+[White "Morphy"]
+[Black "Duke of Brunswick and Count Isouard"]
+[Result "1-0"]
+
+{Opera game} 1.e4 e5 2.Nf3 d6 3.d4 Bg4 $6 4.dxe5 Bxf3 5.Qxf3 dxe5 6.Bc4 Nf6 7.Qb3
+Qe7 8.Nc3 c6 9.Bg5 b5 10.Nxb5 cxb5 11.Bxb5+ Nbd7 12.O-O-O Rd8 13.Rxd7 Rxd7
+14.Rd1 Qe6 15.Bxd7+ Nxd7 (15...Qxd7 16.Qb8+ Ke7 17.Qxe5+) 16.Qb8+! Nxb8 17.Rd8# 1-0
+
+% escaped line
+[Event "?"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/8/R3K3 w Q - 0 1"]
+
+1. O-O-O Kf7 (1... Ke7 2. Rd2 (2. Kb1) ; comment
+2... Ke8) 2. Rd7+ *
+`
+
+func TestPGNReader(t *testing.T) {
+	pr := NewPGNReader(strings.NewReader(testPGN))
+
+	g, err := pr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Tags["White"] != "Morphy" || g.Result() != "1-0" {
+		t.Errorf("got tags %v", g.Tags)
+	}
+	moves := g.MainLine()
+	if len(moves) != 33 {
+		t.Fatalf("got %v moves, want 33", len(moves))
+	}
+	pos := g.Start()
+	for _, m := range moves {
+		pos = Make(pos, m)
+	}
+	if checkTerminal(pos) != errCheckmate {
+		t.Errorf("got %v, want checkmate", FEN(pos))
+	}
+	if c := g.Root.Comments; len(c) != 1 || c[0] != "Opera game" {
+		t.Errorf("got root comments %q", c)
+	}
+	n := g.Root
+	for i := 0; i < 6; i++ {
+		n = n.Children[0]
+	}
+	if len(n.NAGs) != 1 || n.NAGs[0] != 6 {
+		t.Errorf("3...Bg4: got NAGs %v, want [6]", n.NAGs)
+	}
+	for i := 0; i < 23; i++ {
+		n = n.Children[0]
+	}
+	if len(n.Children) != 2 || Coordinate(n.Children[1].Move) != "e6d7" || len(n.Children[1].Children) != 1 {
+		t.Errorf("15.Bxd7+: got continuations %v, want Nxd7 and a variation beginning with Qxd7", len(n.Children))
+	}
+	if nag := n.Children[0].Children[0].NAGs; len(nag) != 1 || nag[0] != 1 {
+		t.Errorf("16.Qb8+!: got NAGs %v, want [1]", nag)
+	}
+
+	g, err = pr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FEN(g.Start()); got != "4k3/8/8/8/8/8/8/R3K3 w Q - 0 1" {
+		t.Errorf("got start %v", got)
+	}
+	if got := Text(g.Start(), g.MainLine()); got != "1.O-O-O Kf7 2.Rd7+" {
+		t.Errorf("got main line %v", got)
+	}
+	v := g.Root.Children[0].Children[1]
+	if got := Text(g.Root.Children[0].Position(), []Move{v.Move, v.Children[0].Move, v.Children[0].Children[0].Move}); got != "1...Ke7 2.Rd2 Ke8" {
+		t.Errorf("got variation %v", got)
+	}
+	if len(v.Children) != 2 || Coordinate(v.Children[1].Move) != "c1b1" {
+		t.Errorf("got nested variation %v", v.Children)
+	}
+
+	if _, err := pr.Read(); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestPGNRoundTrip(t *testing.T) {
+	pr := NewPGNReader(strings.NewReader(testPGN))
+	for {
+		g, err := pr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := WritePGN(&b, g); err != nil {
+			t.Fatal(err)
+		}
+		pgn := b.String()
+		for _, line := range strings.Split(pgn, "\n") {
+			if len(line) > pgnLineLength {
+				t.Errorf("line exceeds %v characters: %v", pgnLineLength, line)
+			}
+		}
+		g2, err := NewPGNReader(strings.NewReader(pgn)).Read()
+		if err != nil {
+			t.Fatalf("reading %v: %v", pgn, err)
+		}
+		var b2 bytes.Buffer
+		if err := WritePGN(&b2, g2); err != nil {
+			t.Fatal(err)
+		}
+		if got := b2.String(); got != pgn {
+			t.Errorf("got\n%v\nwant\n%v", got, pgn)
+		}
+	}
+}
+
+func TestPGNReaderInvalid(t *testing.T) {
+	for _, test := range []string{
+		"1.e4 e5",
+		"[Event \"?\"",
+		"1.e5 *",
+		"1.e4 (e5) *",
+		"(1.e4) *",
+		"1.e4 e5) *",
+		"1.e4 e5 (2.Nf3 *",
+		"[FEN \"8/8 w - - 0 1\"] 1.e4 *",
+		"1.e4 {unterminated",
+		"1.e4 $x *",
+		"1.e4 e5 [Event \"?\"] *",
+	} {
+		if g, err := NewPGNReader(strings.NewReader(test)).Read(); err == nil {
+			t.Errorf("Read(%q): got %v, nil; want error", test, g.MainLine())
+		}
+	}
+}
+
+func TestPGNReaderSkip(t *testing.T) {
+	pr := NewPGNReader(strings.NewReader(`[Event "1"]
+1.e4 e5 2.Nf4 Nc6 3.Bb5 1-0
+
+[Event "2"]
+1.d4 (1.e4 e5 2.Ke3) d5 0-1
+
+[Event "3"]
+1.c4 *
+`))
+	if _, err := pr.Read(); err == nil {
+		t.Error("game 1: got nil error")
+	}
+	if _, err := pr.Read(); err == nil {
+		t.Error("game 2: got nil error")
+	}
+	g, err := pr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Tags["Event"] != "3" || len(g.MainLine()) != 1 {
+		t.Errorf("got tags %v and main line %v, want game 3", g.Tags, g.MainLine())
+	}
+	if _, err := pr.Read(); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
+	"strings"
 )
 
 var (
@@ -16,30 +16,74 @@ var (
 	// readMove returns errResign in response to the "resign" command.
 	// errResign instructs Human's Play method to resign the game.
 	errResign = errors.New("resign")
+
+	// readMove returns errHint in response to the "hint" command.
+	// errHint instructs Human's Play method to print the material that can be won or lost by captures.
+	errHint = errors.New("hint")
 )
 
 // Player is the interface that wraps the Play method.
 //
 // Play analyzes a Position and returns an evaluation score and a Move in that Position.
+// history holds the Zobrist keys of the positions that preceded it in the game, in order of play.
 // Returning the zero value Move{} indicates resignation.
 type Player interface {
-	Play(Position) (Abs, Move)
+	Play(pos Position, history []Zobrist) (Abs, Move)
 }
 
 // Computer can Play without user input.
 type Computer struct {
-	moveTime time.Duration
-	depth    int
+	// tm limits the duration of each search.
+	tm    timeManager
+	depth int
+
+	// nodes and mate, if positive, limit each search as SearchOptions.Nodes and SearchOptions.Mate do.
+	nodes, mate int
+
+	// onIteration, if not nil, is called with the progress of each search as SearchOptions.OnIteration is,
+	// in place of printing the search results.
+	onIteration func(Info)
+
+	// ponder, if not nil, searches on the opponent's time after each move is played.
+	ponder *ponderer
+
+	// book, if not empty, is consulted before searching.
+	// If bookBest is set, its most heavily weighted move is played, and otherwise a move is chosen at random by weight.
+	book     Book
+	bookBest bool
 }
 
 // Play searches pos and returns an evaluation score and a preferred Move.
-func (c Computer) Play(pos Position) (Abs, Move) {
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, c.moveTime)
-	defer cancel()
-
-	results := SearchPosition(ctx, pos, c.depth)
-	fmt.Println(results)
+// If c's book holds moves for pos, one of them is played without searching.
+// If c ponders and pos is the position it predicted, the search continues from where pondering left it.
+func (c Computer) Play(pos Position, history []Zobrist) (Abs, Move) {
+	if m, ok := c.book.Pick(pos, c.bookBest); ok {
+		if c.ponder != nil {
+			c.ponder.stop()
+		}
+		return Abs{}, m
+	}
+	var s *computerSearch
+	if c.ponder != nil {
+		s = c.ponder.finish(pos)
+	}
+	if s == nil {
+		s = c.search(pos, history, false)
+	} else {
+		// ponderhit
+		s.clock.start(c.tm)
+	}
+	results, last := s.wait()
+	if c.onIteration == nil {
+		fmt.Println(results)
+		fmt.Println(last)
+	}
+	if len(results) == 0 {
+		return Abs{}, Move{}
+	}
+	if c.ponder != nil {
+		c.ponder.start(c, pos, history, results[0])
+	}
 	return results[0].score, results[0].move
 }
 
@@ -47,12 +91,14 @@ func (c Computer) Play(pos Position) (Abs, Move) {
 type Human struct{ s *bufio.Scanner }
 
 // Play reads from standard input and returns the engine's evaluation of Pos and the input Move.
-// It accepts moves as the concatenation of the origin and destination squares,
-// followed by the promoted piece in the case of pawn promotion (e2e4, b1c3, e1g1, d7d8q).
+// It accepts moves in standard algebraic notation (e4, Nc3, O-O, d8=Q) or as the concatenation
+// of the origin and destination squares, followed by the promoted piece in the case of pawn promotion
+// (e2e4, b1c3, e1g1, d7d8q).
 // It also accepts the following commands:
 // 	go 		immediately play the engine's preferred move
+// 	hint 	list the captures that win material and the pieces that are en prise
 // 	resign 	resign the game
-func (h Human) Play(pos Position) (Abs, Move) {
+func (h Human) Play(pos Position, history []Zobrist) (Abs, Move) {
 	ch := make(chan Results)
 	// Wait for SearchPosition to return.
 	defer func() { <-ch }()
@@ -62,7 +108,7 @@ func (h Human) Play(pos Position) (Abs, Move) {
 	defer cancel()
 
 	go func() {
-		results := SearchPosition(ctx, pos, 100)
+		results := SearchPosition(ctx, pos, history, SearchOptions{Depth: 100})
 		ch <- results
 		close(ch)
 	}()
@@ -77,6 +123,9 @@ func (h Human) Play(pos Position) (Abs, Move) {
 		case errResign:
 			cancel()
 			return Abs{}, Move{}
+		case errHint:
+			fmt.Println(hint(pos))
+			continue
 		}
 		if err != nil {
 			fmt.Println(err)
@@ -95,10 +144,12 @@ func (h Human) Play(pos Position) (Abs, Move) {
 }
 
 // readMove reads from standard input and returns the input Move.
-// It accepts moves as the concatenation of origin and destination squares,
-// followed by the promoted piece in the case of pawn promotion (e2e4, b1c3, e1g1, d7d8q).
+// It accepts moves in standard algebraic notation (e4, Nc3, O-O, d8=Q) or as the concatenation
+// of origin and destination squares, followed by the promoted piece in the case of pawn promotion
+// (e2e4, b1c3, e1g1, d7d8q).
 // It also accepts the following commands:
 // 	go 		immediately play the engine's preferred move
+// 	hint 	list the captures that win material and the pieces that are en prise
 // 	resign 	resign the game
 // It returns an error if the input is invalid or represents an illegal move.
 // It also returns ErrGo as the result of the "go" command.
@@ -112,14 +163,69 @@ func (h Human) readMove(pos Position) (Move, error) {
 
 // parseInput parses s as a user input command or move in pos.
 func (h Human) parseInput(pos Position, s string) (Move, error) {
+	switch s {
+	case "resign":
+		return Move{}, errResign
+	case "go":
+		return Move{}, errGo
+	case "hint":
+		return Move{}, errHint
+	}
+	if _, _, err := ParseTwoSquares(strings.TrimRight(s, "qrbn")); err == nil {
+		return ParseCoordinate(pos, s)
+	}
+	return ParseAlgebraic(pos, s)
+}
+
+// hint returns a description of the captures available in pos that win material by SEE,
+// and of the pieces of the side to move that the opponent could win by capturing them.
+func hint(pos Position) string {
+	var ml MoveList
+	var wins []string
+	GenLegalCaptures(pos, &ml)
+	for _, m := range ml.Moves() {
+		if see := SEE(pos, m); see > 0 {
+			wins = append(wins, fmt.Sprintf("%v (+%v)", Algebraic(pos, m), see))
+		}
+	}
+	s := "No capture wins material."
+	if len(wins) > 0 {
+		s = "Winning captures: " + strings.Join(wins, ", ")
+	}
+	if IsCheck(pos) {
+		return s
+	}
+
+	// Pass the move to the opponent to find its winning captures.
+	opp := MakeNull(pos)
+	var loss [64]int
+	GenLegalCaptures(opp, &ml)
+	for _, m := range ml.Moves() {
+		if see := SEE(opp, m); m.IsCapture() && see > loss[m.To] {
+			loss[m.To] = see
+		}
+	}
+	var prise []string
+	for sq, n := range loss {
+		if n > 0 {
+			_, p := pos.PieceOn(Square(sq))
+			prise = append(prise, fmt.Sprintf("%v%v (-%v)", pieceLetter[p], Square(sq), n))
+		}
+	}
+	if len(prise) > 0 {
+		return s + "\nEn prise: " + strings.Join(prise, ", ")
+	}
+	return s + "\nNo piece is en prise."
+}
+
+// ParseCoordinate parses s as a Move in pos in pure coordinate notation, the concatenation
+// of the origin and destination squares followed by the promoted piece in the case of
+// pawn promotion (e2e4, b1c3, e1g1, d7d8q).
+// It returns an error if s is invalid or represents an illegal move.
+func ParseCoordinate(pos Position, s string) (Move, error) {
 	var m Move
 	var promote Piece
-	switch {
-	case s == "resign":
-		return m, errResign
-	case s == "go":
-		return m, errGo
-	case len(s) == 5:
+	if len(s) == 5 {
 		switch s[4:] {
 		case "q":
 			promote = Queen
@@ -147,17 +253,21 @@ func (h Human) parseInput(pos Position, s string) (Move, error) {
 		return m, fmt.Errorf("%v piece on square %v", c, from)
 	}
 	cc, cp := pos.PieceOn(to)
-	if cp != None && cc == c {
+	// In Chess960, the king castles by moving to the square of its own rook.
+	castle960 := pos.Chess960 && p == King && cc == c && cp == Rook
+	if castle960 {
+		cp = None
+	} else if cp != None && cc == c {
 		return m, fmt.Errorf("%v piece on square %v", cc, to)
 	}
 	if promote != None && (p != Pawn || (pos.ToMove == White && to.Rank() != 7) || (pos.ToMove == Black && to.Rank() != 0)) {
 		return m, fmt.Errorf("illegal promotion")
 	}
 	var ep bool
-	if pos.ep != 0 && to == pos.ep && cp == Pawn {
-		ep = true
+	if pos.ep != 0 && to == pos.ep && p == Pawn {
+		ep, cp = true, Pawn
 	}
-	m = Move{From: from, To: to, Piece: p, CapturePiece: cp, EP: ep, PromotePiece: promote}
+	m = Move{From: from, To: to, Piece: p, CapturePiece: cp, EP: ep, PromotePiece: promote, Castle960: castle960}
 	if !IsPseudoLegal(pos, m) || !IsLegal(Make(pos, m)) {
 		return m, fmt.Errorf("illegal move")
 	}
@@ -58,6 +58,8 @@ func TestParseInputInvalid(t *testing.T) {
 		"h7h8z", // no such promotion piece
 		"h7h8k", // prohibited promotion piece
 		"h7h8",  // promotion piece not specified
+		"Nc4",   // the knight is pinned
+		"h8",    // promotion piece not specified
 	} {
 		if m, err := h.parseInput(pos, test); err == nil {
 			t.Errorf("parseInput(%v, %v): got %v, nil; want error", InitialPositionFEN, test, m)
@@ -79,6 +81,7 @@ func TestParseInputValid(t *testing.T) {
 	}{
 		{"go", Move{}, errGo},
 		{"resign", Move{}, errResign},
+		{"hint", Move{}, errHint},
 		{"e1e2", Move{From: e1, To: e2, Piece: King}, nil},
 		{"e1c1", Move{From: e1, To: c1, Piece: King}, nil},
 		{"a1a5", Move{From: a1, To: a5, Piece: Rook, CapturePiece: Bishop}, nil},
@@ -86,9 +89,76 @@ func TestParseInputValid(t *testing.T) {
 		{"h7h8r", Move{From: h7, To: h8, Piece: Pawn, PromotePiece: Rook}, nil},
 		{"h7h8b", Move{From: h7, To: h8, Piece: Pawn, PromotePiece: Bishop}, nil},
 		{"h7h8n", Move{From: h7, To: h8, Piece: Pawn, PromotePiece: Knight}, nil},
+		{"Ke2", Move{From: e1, To: e2, Piece: King}, nil},
+		{"O-O-O", Move{From: e1, To: c1, Piece: King}, nil},
+		{"Rxa5", Move{From: a1, To: a5, Piece: Rook, CapturePiece: Bishop}, nil},
+		{"h8=Q+", Move{From: h7, To: h8, Piece: Pawn, PromotePiece: Queen}, nil},
 	} {
 		if m, err := h.parseInput(pos, test.s); m != test.m || err != test.err {
 			t.Errorf("parseInput(%v, %v): got %v, %v; want %v, %v", fen, test.s, m, err, test.m, test.err)
 		}
 	}
 }
+
+func TestHint(t *testing.T) {
+	for _, test := range []struct {
+		fen  string
+		want string
+	}{
+		{InitialPositionFEN, "No capture wins material.\nNo piece is en prise."},
+		{"4k3/8/8/3p4/4N3/1B6/8/4K3 w - - 0 1", "Winning captures: Bxd5 (+100)\nEn prise: Ne4 (-320)"},
+		{"4k3/4n3/8/3p4/4N3/1B6/8/4K3 w - - 0 1", "No capture wins material.\nEn prise: Ne4 (-320)"},
+		{"4k3/8/8/8/8/8/3r4/R2QK3 w - - 0 1", "Winning captures: Qxd2 (+500), Kxd2 (+500)\nEn prise: Qd1 (-400)"},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hint(pos); got != test.want {
+			t.Errorf("hint(%v): got %q, want %q", test.fen, got, test.want)
+		}
+	}
+}
+
+func TestComputerPonder(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	c := Computer{depth: 3, onIteration: func(Info) {}, ponder: new(ponderer)}
+	defer c.ponder.stop()
+
+	// predicted returns the position that the Computer is pondering after playing m in pos, and one it is not.
+	predicted := func(pos Position, m Move) (hit, miss Position) {
+		after := Make(pos, m)
+		if c.ponder.s == nil {
+			t.Fatalf("not pondering after %v", LongAlgebraic(m))
+		}
+		for _, r := range legalResults(after) {
+			if next := Make(after, r.move); next.z == c.ponder.z {
+				hit = next
+			} else {
+				miss = next
+			}
+		}
+		return hit, miss
+	}
+
+	pos := InitialPosition
+	_, m := c.Play(pos, nil)
+	hit, miss := predicted(pos, m)
+	s := c.ponder.s
+	if _, got := c.Play(hit, nil); got != s.results[0].move {
+		t.Errorf("after ponderhit: got %v, want %v from pondering", LongAlgebraic(got), LongAlgebraic(s.results[0].move))
+	}
+
+	_, m = c.Play(miss, nil)
+	_, miss = predicted(miss, m)
+	s = c.ponder.s
+	if _, got := c.Play(miss, nil); !IsPseudoLegal(miss, got) {
+		t.Errorf("after ponder miss: got illegal move %v", LongAlgebraic(got))
+	}
+	select {
+	case <-s.done:
+	default:
+		t.Errorf("pondering not stopped after miss")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import "context"
+
+// A computerSearch is a search by a Computer running in the background.
+// It is not timed until its clock is started.
+type computerSearch struct {
+	cancel context.CancelFunc
+	clock  *searchClock
+
+	// done is closed when the search returns its results.
+	// last describes the progress of the search upon its most recent completed iteration.
+	done    chan struct{}
+	results Results
+	last    Info
+}
+
+// search begins searching pos, preceded by history, in the background. Unless ponder is set, the clock
+// of the search is started with c.tm. Progress is reported to c.onIteration only once the clock has been started.
+func (c Computer) search(pos Position, history []Zobrist, ponder bool) *computerSearch {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &computerSearch{cancel: cancel, clock: newSearchClock(cancel), done: make(chan struct{})}
+	if !ponder {
+		s.clock.start(c.tm)
+	}
+	go func() {
+		defer close(s.done)
+		s.results = SearchPosition(ctx, pos, history, SearchOptions{Depth: c.depth, Nodes: c.nodes, Mate: c.mate, OnIteration: func(info Info) {
+			if c.onIteration != nil && s.clock.running() {
+				c.onIteration(info)
+			}
+			if info.Bound != exactBound {
+				return
+			}
+			s.last = info
+			if s.clock.iterationDone(info.Best.move, info.Best.score.Rel(pos.ToMove)) {
+				cancel()
+			}
+		}})
+	}()
+	return s
+}
+
+// wait waits for s to finish and returns its results and the progress of its most recent completed iteration.
+func (s *computerSearch) wait() (Results, Info) {
+	<-s.done
+	s.clock.stop()
+	s.cancel()
+	return s.results, s.last
+}
+
+// A ponderer searches on the opponent's time the position that follows the predicted reply to a Computer's move.
+type ponderer struct {
+	// s is the search of the predicted position, whose Zobrist key is z, or nil if none is running.
+	s *computerSearch
+	z Zobrist
+}
+
+// start begins pondering after c plays the move of r in pos, if the principal variation of r predicts a reply.
+func (p *ponderer) start(c Computer, pos Position, history []Zobrist, r Result) {
+	p.stop()
+	pv := r.pvMoves()
+	if len(pv) < 2 {
+		return
+	}
+	after := Make(pos, pv[0])
+	predicted := Make(after, pv[1])
+	history = append(append(make([]Zobrist, 0, len(history)+2), history...), pos.z, after.z)
+	p.s, p.z = c.search(predicted, history, true), predicted.z
+}
+
+// finish returns the search begun by p if pos is the position it predicted, so that it continues with its
+// accumulated results once its clock is started. Otherwise finish stops pondering and returns nil.
+func (p *ponderer) finish(pos Position) *computerSearch {
+	if p.s == nil || pos.z != p.z {
+		p.stop()
+		return nil
+	}
+	s := p.s
+	p.s = nil
+	return s
+}
+
+// stop stops pondering, if p is pondering.
+func (p *ponderer) stop() {
+	if p.s == nil {
+		return
+	}
+	p.s.cancel()
+	p.s.wait()
+	p.s = nil
+}
@@ -5,13 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
 	errCheckmate checkmateError
 	errStalemate = errors.New("stalemate")
 	errFiftyMove = errors.New("fifty-move rule")
-	// TODO: threefold repetition
+
+	// errRepetition is returned by Search when a position repeats.
+	errRepetition = errors.New("threefold repetition")
 
 	// openWindow encompasses all possible Rels.
 	openWindow = Window{
@@ -27,22 +32,403 @@ type Search struct {
 
 	// counters tracks the number of nodes searched at each depth.
 	counters []int
+
+	// qnodes tracks the number of nodes searched by quiescence search.
+	qnodes int
+
+	// evasions reports whether quiescence search considers all legal moves when in check,
+	// rather than only captures and promotions.
+	evasions bool
+
+	// tt is the transposition table, or nil if none is used.
+	tt *TransTable
+
+	// ttProbes and ttHits track the number of transposition table lookups and the number that found an entry.
+	ttProbes, ttHits int
+
+	// ply is the number of plies from the root of the search to the current node.
+	ply int
+
+	// selDepth is the greatest ply of any node searched.
+	selDepth int
+
+	// nodes is the number of nodes searched, including by quiescence search.
+	// If nodeLimit is positive, stop is called once nodes reaches it.
+	nodes, nodeLimit int
+	stop             context.CancelFunc
+
+	// multiPV is the number of root moves to search to exact scores.
+	multiPV int
+
+	// ordering reports whether to order moves by the killer and history heuristics and MVV-LVA.
+	// Otherwise only the hash move is searched out of the order in which moves are generated.
+	ordering bool
+
+	// killers holds the two most recent quiet moves at each ply that caused a beta cutoff.
+	killers [][2]Move
+
+	// history accumulates the success of quiet moves in causing beta cutoffs, indexed by Color and Squares.
+	history [2][64][64]int
+
+	// path holds the Zobrist keys of the positions preceding the current node:
+	// the game history followed by the positions searched from the root.
+	path []Zobrist
+
+	// pvs, nullMove, lmr, and checkExt report whether to employ principal variation search,
+	// null-move pruning, late move reductions, and check extensions, respectively.
+	pvs, nullMove, lmr, checkExt bool
+
+	// rootDepth is the depth of the current iteration. Checks are not extended beyond twice this many plies.
+	rootDepth int
+
+	// afterNull reports whether the current node was reached by a null move.
+	afterNull bool
+
+	// tb is the tablebase probed for the outcomes of positions with few pieces, or nil if none is used.
+	// tbHits tracks the number of positions found in it.
+	tb     tablebase
+	tbHits int
+
+	// dtm holds the distance-to-mate tables probed for exact checkmate distances, or nil if none are used.
+	// Its positions are counted in tbHits.
+	dtm *DTM
 }
 
-// SearchPosition searches a Position to the specified depth via iterative deepening and returns the search results.
-func SearchPosition(ctx context.Context, pos Position, depth int) Results {
+// quiescenceEvasions reports whether quiescence search considers all legal moves when in check.
+var quiescenceEvasions bool
+
+// multiPV is the number of principal variations that SearchPosition searches to exact scores.
+var multiPV = 1
+
+// Each selective search technique may be disabled to measure its effect on playing strength.
+var (
+	// principalVariationSearch reports whether to search all moves after the first with a null window,
+	// re-searching those that improve on alpha with the full window.
+	principalVariationSearch = true
+
+	// nullMovePruning reports whether to cut off nodes in which passing the move still reaches beta
+	// in a reduced-depth search.
+	nullMovePruning = true
+
+	// lateMoveReductions reports whether to search quiet moves late in the move order to reduced depth,
+	// re-searching those that improve on alpha to full depth.
+	lateMoveReductions = true
+
+	// checkExtensions reports whether to search moves that give check one ply deeper.
+	checkExtensions = true
+)
+
+const (
+	// nullMoveReduction is the depth by which a null-move search is reduced, in addition to the ply passed.
+	nullMoveReduction = 2
+
+	// lmrMoves is the number of moves of a node that are searched before any are reduced,
+	// and lmrMinDepth is the minimum depth of a node whose moves are reduced.
+	lmrMoves    = 3
+	lmrMinDepth = 3
+)
+
+// aspirationWindows reports whether each iteration of SearchPosition after the first searches within a narrow Window
+// around the score of the previous iteration, widening it if the score falls outside.
+var aspirationWindows = true
+
+const (
+	// aspirationDelta is the initial distance in centipawns from the previous iteration's score to each bound
+	// of an aspiration window. It doubles each time the search fails high or low.
+	aspirationDelta = 25
+
+	// aspirationMaxDelta is the distance beyond which a bound that fails is opened entirely.
+	aspirationMaxDelta = 400
+)
+
+// searchThreads is the number of goroutines with which SearchPosition searches in parallel.
+// Parallel search requires a transposition table to share results between them.
+var searchThreads = 1
+
+// Info describes the progress of a search.
+type Info struct {
+	// Depth is the depth of the most recent iteration, and SelDepth is the greatest number of plies
+	// from the root of any node searched, including by quiescence search.
+	Depth, SelDepth int
+
+	// Nodes is the total number of nodes searched, including the QNodes searched by quiescence search
+	// and the nodes searched by helper threads.
+	Nodes, QNodes int
+
+	// TTProbes and TTHits are the number of transposition table lookups and the number that found an entry.
+	TTProbes, TTHits int
+
+	// TBHits is the number of positions found in the endgame tablebase.
+	TBHits int
+
+	// Elapsed is the time since the search began, and NPS is the number of nodes searched per second.
+	Elapsed time.Duration
+	NPS     int
+
+	// Hashfull is the fraction of the transposition table used in the current search, in permille.
+	Hashfull int
+
+	// Bound is exactBound for a completed iteration. It is lowerBound or upperBound if the search
+	// of the iteration failed high or low, respectively, and will be repeated within a wider Window.
+	Bound bound
+
+	// Best is the Result of the best move, and PV holds its principal variation.
+	Best Result
+	PV   []Move
+
+	// Results holds the Results of all moves, best first.
+	// The search updates them in place as it continues, along with the continuation of Best.
+	Results Results
+}
+
+// hitRate returns the fraction of transposition table lookups that found an entry.
+func (info Info) hitRate() float64 {
+	if info.TTProbes == 0 {
+		return 0
+	}
+	return float64(info.TTHits) / float64(info.TTProbes)
+}
+
+// String returns a string representation of info.
+func (info Info) String() string {
+	return fmt.Sprintf("depth %v (%v selective), %v nodes (%v quiescence) in %v, %v nodes/s, %.1f%% transposition table hits",
+		info.Depth, info.SelDepth, info.Nodes, info.QNodes, info.Elapsed.Round(time.Millisecond), info.NPS, 100*info.hitRate())
+}
+
+// SearchOptions configures a search by SearchPosition.
+type SearchOptions struct {
+	// Depth is the depth of the final iteration.
+	Depth int
+
+	// Nodes, if positive, limits the number of nodes searched. Only the nodes searched by the main thread are counted,
+	// so that a search with a single thread stops at the same point each time.
+	Nodes int
+
+	// Mate, if positive, limits the search to finding a checkmate in at most Mate moves by the side to move.
+	// The search ends as soon as it finds one, which is the shortest, or once it is deep enough to show that none exists.
+	// Null-move pruning and late move reductions, which may miss a checkmate, are disabled.
+	Mate int
+
+	// OnIteration, if not nil, is called with the progress of the search upon each completed iteration,
+	// and each search that fails outside of its aspiration window, as indicated by the Bound of the Info.
+	// It is called from the searching goroutine, and may stop the search by canceling its Context.
+	OnIteration func(Info)
+}
+
+// SearchPosition searches a Position via iterative deepening as configured by opts and returns the search results,
+// which are those of the last completed iteration if the search is interrupted.
+// history holds the Zobrist keys of the positions that preceded pos in the game, in order of play.
+func SearchPosition(ctx context.Context, pos Position, history []Zobrist, opts SearchOptions) Results {
+	start := time.Now()
 	var rs Results
-	s := Search{
-		allowCutoff: true,
-		counters:    make([]int, depth+1),
+	depth := opts.Depth
+	if opts.Mate > 0 && (depth <= 0 || depth > 2*opts.Mate-1) {
+		// A checkmate in Mate moves is delivered on the 2*Mate-1th ply.
+		depth = 2*opts.Mate - 1
+	}
+	s := newSearch(history, depth)
+	s.multiPV = multiPV
+	if opts.Mate > 0 {
+		s.exhaustive()
 	}
+	if opts.Nodes > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		s.nodeLimit, s.stop = opts.Nodes, cancel
+	}
+	if s.tt != nil {
+		s.tt.NewSearch()
+	}
+	// If the tablebase holds pos, only the moves that best preserve its outcome are searched,
+	// unless the distance-to-mate tables hold it, which find the quickest checkmate.
+	if !s.dtm.holds(pos) {
+		rs = tablebaseResults(s.tb, pos)
+	}
+
+	// Helper threads search the same position only to fill the shared transposition table.
+	var helperNodes int64
+	if s.tt != nil && searchThreads > 1 {
+		hctx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+		for i := 1; i < searchThreads; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				searchHelper(hctx, pos, history, depth, i, opts.Mate > 0, &helperNodes)
+			}(i)
+		}
+	}
+
+	// results returns the results of the search, with only the checkmates within the limit of a mate search.
+	results := func() Results {
+		if opts.Mate > 0 {
+			return limitMates(pos, rs, opts.Mate)
+		}
+		return rs
+	}
+
+	// report calls opts.OnIteration with the progress of the search to depth d and reports whether to continue.
+	report := func(d int, b bound) bool {
+		if opts.OnIteration == nil {
+			return true
+		}
+		info := s.info(d)
+		info.Nodes += int(atomic.LoadInt64(&helperNodes))
+		info.Elapsed = time.Since(start)
+		if info.Elapsed > 0 {
+			info.NPS = int(float64(info.Nodes) / info.Elapsed.Seconds())
+		}
+		if s.tt != nil {
+			info.Hashfull = s.tt.Hashfull()
+		}
+		info.Bound = b
+		res := results()
+		info.Best, info.PV, info.Results = res[0], res[0].pvMoves(), res
+		opts.OnIteration(info)
+		return ctx.Err() == nil
+	}
+
+	// completed holds the Results of the last iteration searched to exact scores. They are returned if the search
+	// is interrupted during a later iteration, whose moves may be only partly searched or, after a search that failed
+	// outside of its aspiration window, not searched at all.
+	var completed Results
+
+iterations:
 	for d := 1; d <= depth; d++ {
-		_, rs = s.negamax(ctx, pos, rs, openWindow, d)
-		if ctx.Err() != nil {
+		w := openWindow
+		if aspirationWindows && d > 1 && s.multiPV == 1 {
+			// With multiple principal variations, the other moves may score far below the best.
+			w = aspirationWindow(rs[0].score.Rel(pos.ToMove))
+		}
+		for delta := aspirationDelta; ; delta *= 2 {
+			var score Rel
+			score, rs = s.negamax(ctx, pos, rs, w, d)
+			if ctx.Err() != nil || len(rs) == 0 {
+				if completed != nil {
+					rs = completed
+				}
+				break iterations
+			}
+			b := exactBound
+			switch {
+			case w.alpha != openWindow.alpha && !Less(Score(w.alpha), Score(score)):
+				b = upperBound
+			case w.beta != openWindow.beta && !Less(Score(score), Score(w.beta)):
+				b = lowerBound
+			}
+			if b == exactBound {
+				break
+			}
+			if !report(d, b) {
+				rs = completed
+				break iterations
+			}
+			w = w.widen(b, delta)
+			// The scores in rs are bounds of w, which a search of the same depth would take as exact.
+			rs = rootResults(rs)
+		}
+		completed = pvCopy(rs)
+		if !report(d, exactBound) {
+			// OnIteration stopped the search; do not begin another iteration.
+			break
+		}
+		if n, ok := rs[0].score.Rel(pos.ToMove).err.(checkmateError); ok && opts.Mate > 0 && n&1 != 0 && n <= checkmateError(2*opts.Mate-1) {
+			// No shorter checkmate was found by an earlier iteration.
 			break
 		}
 	}
-	return rs
+	return results()
+}
+
+// pvCopy returns a copy of rs in which each continuation holds only its principal variation,
+// so that it is not modified as a later search updates rs in place.
+func pvCopy(rs Results) Results {
+	c := make(Results, len(rs))
+	for i, r := range rs {
+		c[i] = r
+		if len(r.cont) > 0 {
+			c[i].cont = pvCopy(r.cont[:1])
+		}
+	}
+	return c
+}
+
+// limitMates returns a copy of rs, the Results of a search of pos, in which the moves that checkmate in more than mate moves,
+// which a search for a checkmate in mate moves may find by extending checks, are scored by evaluation instead.
+func limitMates(pos Position, rs Results, mate int) Results {
+	limited := append(Results(nil), rs...)
+	for i, r := range limited {
+		if n, ok := r.score.Rel(pos.ToMove).err.(checkmateError); ok && n&1 != 0 && n > checkmateError(2*mate-1) {
+			limited[i].score = Eval(Make(pos, r.move))
+		}
+	}
+	limited.SortFor(pos.ToMove)
+	return limited
+}
+
+// newSearch returns a Search to the specified depth of a position preceded by history.
+func newSearch(history []Zobrist, depth int) Search {
+	return Search{
+		allowCutoff: true,
+		ordering:    true,
+		counters:    make([]int, depth+1),
+		evasions:    quiescenceEvasions,
+		tt:          hashTable,
+		path:        append([]Zobrist(nil), history...),
+		pvs:         principalVariationSearch,
+		nullMove:    nullMovePruning,
+		lmr:         lateMoveReductions,
+		checkExt:    checkExtensions,
+		tb:          tablebases,
+		dtm:         dtmTables,
+	}
+}
+
+// exhaustive disables the selective search techniques of s that may miss the best move.
+func (s *Search) exhaustive() { s.nullMove, s.lmr = false, false }
+
+// searchHelper searches pos via iterative deepening as the ith helper thread of a parallel search
+// and adds the number of nodes it searches to nodes. The helpers begin at alternating depths
+// and with the root moves in different orders, so that they tend to search different parts of the tree.
+// If exhaustive is set, the helpers do not employ selective search techniques that may miss the best move.
+func searchHelper(ctx context.Context, pos Position, history []Zobrist, depth, i int, exhaustive bool, nodes *int64) {
+	s := newSearch(history, depth)
+	if exhaustive {
+		s.exhaustive()
+	}
+	var rs Results
+	if !s.dtm.holds(pos) {
+		rs = tablebaseResults(s.tb, pos)
+	}
+	if rs == nil {
+		rs = legalResults(pos)
+	}
+	if len(rs) == 0 {
+		return
+	}
+	k := i % len(rs)
+	rs = append(append(make(Results, 0, len(rs)), rs[k:]...), rs[:k]...)
+	var counted int
+	for d := 1 + i%2; d <= depth && ctx.Err() == nil && len(rs) > 0; d++ {
+		_, rs = s.negamax(ctx, pos, rs, openWindow, d)
+		n := s.info(d).Nodes
+		atomic.AddInt64(nodes, int64(n-counted))
+		counted = n
+	}
+}
+
+// info returns the statistics of s upon completion of the specified depth.
+func (s *Search) info(depth int) Info {
+	info := Info{Depth: depth, SelDepth: s.selDepth, Nodes: s.qnodes, QNodes: s.qnodes, TTProbes: s.ttProbes, TTHits: s.ttHits, TBHits: s.tbHits, Bound: exactBound}
+	for _, c := range s.counters {
+		info.Nodes += c
+	}
+	return info
 }
 
 // negamax recursively searches a Position to the specified depth and returns the evaluation score
@@ -57,14 +443,66 @@ func (s *Search) negamax(
 	depth int,
 ) (bestScore Rel, results Results) {
 	s.counters[len(s.counters)-1-depth]++
+	if s.nodes++; s.nodeLimit > 0 && s.nodes >= s.nodeLimit {
+		s.stop()
+	}
+	if s.ply > s.selDepth {
+		s.selDepth = s.ply
+	}
+	if s.ply == 0 {
+		s.rootDepth = depth
+	}
+	// A search that fails low or high returns a bound of w, which must not be taken as a drawn score,
+	// lest the move be considered to draw.
+	w = Window{w.alpha.bound(), w.beta.bound()}
 
 	err := checkTerminal(pos)
 	if err != nil && (s.allowCutoff || err != errInsufficient) {
 		// Do not cut off during perft in the case of insufficient material
 		return Rel{err: err}, nil
 	}
+	if s.allowCutoff && s.ply > 0 && s.isRepetition(pos) {
+		return Rel{err: errRepetition}, nil
+	}
+	if s.allowCutoff && s.ply > 0 {
+		// The distance-to-mate tables give the exact score, disregarding the fifty-move rule.
+		if score, ok := s.dtm.probe(pos); ok {
+			s.tbHits++
+			return score, nil
+		}
+	}
+	if s.tb != nil && s.allowCutoff && s.ply > 0 && pos.HalfMove == 0 && tablebaseHolds(s.tb, pos) {
+		// The fifty-move counter was just reset, so the outcome is exactly as the tablebase gives it.
+		if w, ok := s.tb.probeWDL(pos); ok {
+			s.tbHits++
+			return wdlScore(w), nil
+		}
+	}
+
+	var hashMove Move
+	if s.tt != nil && s.allowCutoff {
+		s.ttProbes++
+		if e, ok := s.tt.probe(pos.z); ok {
+			s.ttHits++
+			hashMove = e.move
+			if s.ply > 0 && int(e.depth) >= depth {
+				// Return a score within the window, as a search would.
+				score := e.score.Rel()
+				switch {
+				case e.bound != upperBound && !Less(Score(score), Score(w.beta)):
+					return w.beta, rs
+				case e.bound != lowerBound && !Less(Score(w.alpha), Score(score)):
+					return w.alpha, rs
+				case e.bound == exactBound:
+					return score, rs
+				}
+			}
+		}
+	}
+
 	if len(rs) == 0 {
 		rs = legalResults(pos)
+		s.orderMoves(pos, rs, hashMove)
 	}
 	// Invariant: len(rs) > 0 and rs contains only legal moves
 	if w.beta.err == errCheckmate {
@@ -72,8 +510,11 @@ func (s *Search) negamax(
 		return rs[0].score.Rel(pos.ToMove), rs
 	}
 	if depth == 0 {
-		score := Eval(pos)
-		return score.Rel(pos.ToMove), rs
+		if !s.allowCutoff {
+			score := Eval(pos)
+			return score.Rel(pos.ToMove), rs
+		}
+		return s.quiesce(pos, w), rs
 	}
 	if s.allowCutoff && deepEnough(rs, depth) {
 		return rs[0].score.Rel(pos.ToMove), rs
@@ -83,35 +524,233 @@ func (s *Search) negamax(
 		w = Window{Rel{err: errCheckmate.Prev().Prev()}, w.beta}
 	}
 
+	inCheck := s.allowCutoff && IsCheck(pos)
+	if s.nullMove && s.allowCutoff && s.ply > 0 && !s.afterNull && !inCheck && depth > nullMoveReduction &&
+		hasPieces(pos, pos.ToMove) && !isMateScore(w.beta) && !Less(Score(Eval(pos).Rel(pos.ToMove)), Score(w.beta)) {
+		// Passing the move is almost always worse than the best move, unless the side to move is in zugzwang,
+		// which is rare when it has pieces other than pawns. If passing still reaches beta, the best move is assumed to as well.
+		// A checkmate found after passing does not show that one can be forced, so beta must not be a checkmate score.
+		nw := Window{Rel{n: w.beta.n - 1}, w.beta}
+		score, _ := s.searchChild(ctx, pos, MakeNull(pos), nil, nw, depth-1-nullMoveReduction, true)
+		if !Less(Score(score), Score(w.beta)) {
+			return w.beta, rs
+		}
+	}
+
+	alpha := w.alpha
+	b := upperBound
+	var bestMove Move
+	// With multiple principal variations, each root move is searched with a lower bound
+	// no greater than the score of the multiPVth best move so far, so that the best moves have exact scores.
+	lower := w.alpha
+	var top []Rel
+	var searched int
 	for _, r := range rs {
+		var score Rel
 		if r.score.err != nil && s.allowCutoff {
 			// The move is already known not to avoid a game-ending state; no need to search it further.
-			continue
-		}
+			// Its score still bounds the score of pos, unless it only showed that the move is no better than another.
+			if r.failLow {
+				continue
+			}
+			score = r.score.Rel(pos.ToMove)
+		} else {
+			mw := w
+			if s.ply == 0 && s.multiPV > 1 {
+				mw.alpha = lower
+				if len(top) >= s.multiPV {
+					mw.alpha = top[s.multiPV-1]
+				}
+			}
 
-		score, cont := s.negamax(ctx, Make(pos, r.move), r.cont, w.Next(), depth-1)
-		score = score.Prev()
+			var cont Results
+			var reduction int
+			score, cont, reduction = s.searchMove(ctx, pos, r, mw, depth, searched, inCheck)
+			searched++
 
-		rs.Update(Result{move: r.move, score: score.Abs(pos.ToMove), depth: depth - 1, cont: cont})
+			if depth >= 3 && ctx.Err() != nil {
+				// The search is incomplete; do not store its result, nor the score of the interrupted move.
+				b = noBound
+				break
+			}
 
-		if depth >= 3 && ctx.Err() != nil {
-			break
-		}
+			// A score no greater than the lower bound only shows that the move is no better.
+			failLow := s.allowCutoff && !Less(Score(mw.alpha), Score(score))
+			rs.Update(Result{move: r.move, score: score.Abs(pos.ToMove), depth: depth - 1 - reduction, cont: cont, failLow: failLow})
+			if s.ply == 0 && s.multiPV > 1 && !failLow {
+				top = insertScore(top, score)
+			}
 
-		if !s.allowCutoff {
-			continue
+			if !s.allowCutoff {
+				continue
+			}
 		}
 		var ok bool
 		w, ok = w.Constrain(score)
 		if !ok {
 			// beta cutoff
+			b, bestMove = lowerBound, r.move
+			s.cutoff(pos.ToMove, r.move, depth)
 			break
 		}
+		if Less(Score(alpha), Score(w.alpha)) {
+			b, bestMove, alpha = exactBound, r.move, w.alpha
+		}
 	}
 	rs.SortFor(pos.ToMove)
+	if s.tt != nil && s.allowCutoff && b != noBound {
+		s.tt.store(pos.z, bestMove, w.alpha, b, depth)
+	}
 	return w.alpha, rs
 }
 
+// searchMove searches the move of r, the Result of the searched+1th move to be searched in pos, within w
+// and returns its score relative to the side to move in pos, its continuation, and the depth by which it was reduced.
+// The move is searched to depth-1, or to depth if it gives check and checks are extended.
+// Moves expected to be no better than alpha are searched with a null window, which only shows whether they are,
+// and late quiet moves also to reduced depth. Those that improve on alpha are searched again.
+func (s *Search) searchMove(ctx context.Context, pos Position, r Result, w Window, depth, searched int, inCheck bool) (score Rel, cont Results, reduction int) {
+	child := Make(pos, r.move)
+	d := depth - 1
+	givesCheck := s.allowCutoff && (s.checkExt || s.lmr) && IsCheck(child)
+	if s.checkExt && givesCheck && s.ply < 2*s.rootDepth {
+		d++
+	}
+	sw := w
+	if nw, ok := nullWindow(w.alpha); ok && s.allowCutoff {
+		if s.lmr && s.ply > 0 && !inCheck && !givesCheck && isQuiet(r.move) {
+			reduction = lateMoveReduction(searched, depth)
+		}
+		// The principal variations are searched with the full window.
+		pv := searched == 0 || (s.ply == 0 && searched < s.multiPV)
+		if reduction > 0 || (s.pvs && !pv) {
+			sw = nw
+		}
+	}
+	score, cont = s.searchChild(ctx, pos, child, r.cont, sw, d-reduction, false)
+	if reduction > 0 && Less(Score(w.alpha), Score(score)) {
+		// The reduced search improved on alpha; verify it to full depth.
+		reduction = 0
+		score, cont = s.searchChild(ctx, pos, child, cont, sw, d, false)
+	}
+	if sw != w && Less(Score(w.alpha), Score(score)) && Less(Score(score), Score(w.beta)) {
+		// The move improves on alpha; search it again to find its exact score.
+		// The null-window search left only bounds in its Results, which a search to the same depth
+		// would take as exact, so the search begins anew.
+		score, cont = s.searchChild(ctx, pos, child, nil, w, d, false)
+	}
+	return score, cont, reduction
+}
+
+// searchChild searches child, the Position resulting from a move or, if null is set, a null move in pos,
+// to the specified depth within w, and returns its score relative to the side to move in pos and its search results.
+func (s *Search) searchChild(ctx context.Context, pos, child Position, rs Results, w Window, depth int, null bool) (Rel, Results) {
+	s.ply++
+	s.path = append(s.path, pos.z)
+	afterNull := s.afterNull
+	s.afterNull = null
+	score, rs := s.negamax(ctx, child, rs, w.Next(), depth)
+	s.afterNull = afterNull
+	s.path = s.path[:len(s.path)-1]
+	s.ply--
+	return score.Prev(), rs
+}
+
+// lateMoveReduction returns the depth by which to reduce the search of a quiet move
+// after the specified number of moves have been searched in a node searched to depth.
+func lateMoveReduction(searched, depth int) int {
+	switch {
+	case searched < lmrMoves || depth < lmrMinDepth:
+		return 0
+	case searched >= 4*lmrMoves && depth >= 2*lmrMinDepth:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// hasPieces reports whether c has any pieces other than its king and pawns in pos.
+func hasPieces(pos Position, c Color) bool {
+	return pos.b[c][All]&^(pos.b[c][Pawn]|pos.b[c][King]) != 0
+}
+
+// quiesce searches the captures and promotions available in pos until a quiet position is reached
+// and returns the evaluation score relative to the side to move. Each side may instead accept
+// the static evaluation of a position (stand pat), unless s.evasions is set and it is in check,
+// in which case all of its legal moves are searched. Captures that lose material by SEE are not searched.
+// quiesce employs fail-hard alpha-beta pruning outside of w, cutting off at scores of at least beta as Constrain does.
+func (s *Search) quiesce(pos Position, w Window) Rel {
+	s.qnodes++
+	if s.nodes++; s.nodeLimit > 0 && s.nodes >= s.nodeLimit {
+		s.stop()
+	}
+	if s.ply > s.selDepth {
+		s.selDepth = s.ply
+	}
+	if w.beta.err == errCheckmate {
+		// An alternative move from this position's parent delivers mate; no need to search this one.
+		return w.beta
+	}
+
+	var ml MoveList
+	evading := s.evasions && IsCheck(pos)
+	if evading {
+		GenLegalMoves(pos, &ml)
+		if ml.Len() == 0 {
+			// Checkmate is no better than alpha.
+			return w.alpha
+		}
+		if w.alpha.err == errCheckmate {
+			// There is at least one legal move, so the worst case is not checkmate.
+			w = Window{Rel{err: errCheckmate.Prev().Prev()}, w.beta}
+		}
+	} else {
+		standPat := Eval(pos).Rel(pos.ToMove)
+		if !Less(Score(standPat), Score(w.beta)) {
+			return w.beta
+		}
+		if Less(Score(w.alpha), Score(standPat)) {
+			w.alpha = standPat
+		}
+		GenLegalCaptures(pos, &ml)
+		orderCaptures(&ml)
+	}
+
+	for _, m := range ml.Moves() {
+		if !evading && SEE(pos, m) < 0 {
+			// A capture that loses material is unlikely to improve on standing pat.
+			continue
+		}
+		s.ply++
+		score := s.quiesce(Make(pos, m), w.Next()).Prev()
+		s.ply--
+		if !Less(Score(score), Score(w.beta)) {
+			return w.beta
+		}
+		if Less(Score(w.alpha), Score(score)) {
+			w.alpha = score
+		}
+	}
+	return w.alpha
+}
+
+// isRepetition reports whether pos repeats a position that is to be scored as a draw:
+// either a position in the search path, or one that has already occurred twice in the game.
+// Only positions since the last capture or pawn move are considered.
+func (s *Search) isRepetition(pos Position) bool {
+	var n int
+	// The side to move must be the same, and a repetition requires at least two moves by each side.
+	for k := 4; k <= pos.HalfMove && k <= len(s.path); k += 2 {
+		if s.path[len(s.path)-k] != pos.z {
+			continue
+		}
+		if n++; k <= s.ply || n == 2 {
+			return true
+		}
+	}
+	return false
+}
+
 // checkTerminal returns an error describing the type of terminal position represented by pos, or nil if pos is not terminal.
 func checkTerminal(pos Position) error {
 	if IsMate(pos) {
@@ -129,14 +768,33 @@ func checkTerminal(pos Position) error {
 
 // legalResults returns a Results containing all legal moves in pos.
 func legalResults(pos Position) Results {
-	moves := LegalMoves(pos)
-	rs := make(Results, 0, len(moves))
-	for _, m := range moves {
+	var ml MoveList
+	GenLegalMoves(pos, &ml)
+	rs := make(Results, 0, ml.Len())
+	for _, m := range ml.Moves() {
 		rs = append(rs, Result{move: m})
 	}
 	return rs
 }
 
+// rootResults returns Results of the moves of rs, in the same order, with no scores or continuations.
+func rootResults(rs Results) Results {
+	fresh := make(Results, len(rs))
+	for i, r := range rs {
+		fresh[i] = Result{move: r.move}
+	}
+	return fresh
+}
+
+// insertScore inserts s into scores, which is sorted in decreasing order, and returns the result.
+func insertScore(scores []Rel, s Rel) []Rel {
+	i := sort.Search(len(scores), func(i int) bool { return Less(Score(scores[i]), Score(s)) })
+	scores = append(scores, Rel{})
+	copy(scores[i+1:], scores[i:])
+	scores[i] = s
+	return scores
+}
+
 // deepEnough reports whether rs stores the results of a position search to at least the specified depth.
 func deepEnough(rs Results, depth int) bool {
 	// rs is already deep enough if all non-terminal elements have been searched to at least depth-1,
@@ -153,7 +811,9 @@ func deepEnough(rs Results, depth int) bool {
 // A move is pseudo-legal if the square to be moved from contains the specified piece
 // and the piece is capable of moving to the target square if doing so would not put the king in check.
 func IsPseudoLegal(pos Position, move Move) bool {
-	for _, m := range PseudoLegalMoves(pos) {
+	var ml MoveList
+	GenPseudoLegalMoves(pos, &ml)
+	for _, m := range ml.Moves() {
 		if m == move {
 			return true
 		}
@@ -183,6 +843,10 @@ type Result struct {
 	score Abs
 	depth int
 	cont  Results
+
+	// failLow reports whether score is only an upper bound, because the search showed
+	// that the move is no better than another.
+	failLow bool
 }
 
 // String returns a string representation of r, including its principal variation.
@@ -202,6 +866,14 @@ func (r Result) PV() string {
 	return LongAlgebraic(r.move) + " " + r.cont[0].PV()
 }
 
+// pvMoves returns the Moves of r's principal variation.
+func (r Result) pvMoves() []Move {
+	if r.depth == 0 || len(r.cont) == 0 {
+		return []Move{r.move}
+	}
+	return append([]Move{r.move}, r.cont[0].pvMoves()...)
+}
+
 // Results contains the results of a search. Functions returning a Results should sort it before returning.
 type Results []Result
 
@@ -209,23 +881,41 @@ type Results []Result
 // The sort is not guaranteed to be stable.
 func (rs Results) SortFor(c Color) {
 	// Sort first by terminal condition, then by depth decreasing,
-	// then by Score decreasing/increasing for White/Black,
+	// then by Score decreasing/increasing for White/Black, then by exact scores before upper bounds
+	// (including equal checkmate scores, whose upper bounds arise when another move is known to checkmate),
 	// and then by origin and destination Square increasing.
+	// Drawn results are exact, so they sort among the results of the deepest search.
+	var maxDepth int
+	for _, r := range rs {
+		if r.score.err == nil && r.depth > maxDepth {
+			maxDepth = r.depth
+		}
+	}
+	depth := func(r Result) int {
+		if r.score.err != nil {
+			return maxDepth
+		}
+		return r.depth
+	}
 	sort.Slice(rs, func(i, j int) bool {
 		less := Less(Score(rs[i].score), Score(rs[j].score))
-		if ierr, jerr := rs[i].score.err, rs[j].score.err; ierr != nil || jerr != nil {
-			_, ich := ierr.(checkmateError)
-			_, jch := jerr.(checkmateError)
-			if ich || jch {
-				return !less
+		greater := Less(Score(rs[j].score), Score(rs[i].score))
+		_, ich := rs[i].score.err.(checkmateError)
+		_, jch := rs[j].score.err.(checkmateError)
+		if ich || jch {
+			if less || greater {
+				return greater
+			}
+		} else {
+			if di, dj := depth(rs[i]), depth(rs[j]); di != dj {
+				return di > dj
+			}
+			if less || greater {
+				return greater == (c == White)
 			}
-			return !less == (c == White)
-		}
-		if rs[i].depth != rs[j].depth {
-			return rs[i].depth > rs[j].depth
 		}
-		if rs[i].score != rs[j].score {
-			return !less == (c == White)
+		if rs[i].failLow != rs[j].failLow {
+			return rs[j].failLow
 		}
 		return rs.squareSort(i, j)
 	})
@@ -310,17 +1000,79 @@ func (s Rel) Next() Rel {
 	return Rel{-s.n, s.err}
 }
 
+// isMateScore reports whether s is a checkmate score.
+func isMateScore(s Rel) bool {
+	_, ok := s.err.(checkmateError)
+	return ok
+}
+
+// bound returns s for use as a bound of a Window: without a drawing error, if s has one.
+func (s Rel) bound() Rel {
+	if s.err != nil && !isMateScore(s) {
+		return Rel{n: s.n}
+	}
+	return s
+}
+
 // Window represents the bounds of a position's evaluation.
 type Window struct{ alpha, beta Rel }
 
+// nullWindow returns the Window whose beta is the least Rel greater than alpha,
+// so that a search within it only shows whether a position's score is greater than alpha.
+// It returns false if alpha is a checkmate score. A search that fails high returns beta as a lower bound,
+// but Results record checkmate scores as exact, so a bound may only be a checkmate score if one has been found.
+func nullWindow(alpha Rel) (Window, bool) {
+	if isMateScore(alpha) {
+		return Window{}, false
+	}
+	return Window{alpha, Rel{n: alpha.n + 1}}, true
+}
+
+// aspirationWindow returns the Window within which to search a position whose score is expected to be close to s.
+// A checkmate score is expected to remain unchanged, so the Window admits checkmates one move sooner or later.
+func aspirationWindow(s Rel) Window {
+	n, ok := s.err.(checkmateError)
+	if !ok {
+		return Window{Rel{n: s.n - aspirationDelta}, Rel{n: s.n + aspirationDelta}}
+	}
+	later, sooner := s.Prev().Prev(), openWindow.beta
+	if n >= 2 {
+		sooner = s.Next().Next()
+	}
+	if n&1 == 0 {
+		// The side to move is checkmated, so a checkmate later is better.
+		return Window{sooner, later}
+	}
+	return Window{later, sooner}
+}
+
+// widen returns w with the bound that the score of a search failed at moved out by delta centipawns:
+// the lower bound if b is upperBound, or the upper bound if b is lowerBound.
+// A checkmate bound, or one that would be moved out by more than aspirationMaxDelta, is opened entirely.
+func (w Window) widen(b bound, delta int) Window {
+	open := delta > aspirationMaxDelta
+	switch {
+	case b == upperBound && (open || isMateScore(w.alpha)):
+		w.alpha = openWindow.alpha
+	case b == upperBound:
+		w.alpha = Rel{n: w.alpha.n - delta}
+	case open || isMateScore(w.beta):
+		w.beta = openWindow.beta
+	default:
+		w.beta = Rel{n: w.beta.n + delta}
+	}
+	return w
+}
+
 // Constrain updates the lower bound of w, if applicable, and returns the updated Window
 // and a boolean value reporting whether the returned Window remains valid.
-// Constrain employs fail-hard beta cutoff. Invariant: alpha <= beta in the returned Window.
+// Constrain employs fail-hard beta cutoff at scores of at least beta, so that the search of a position
+// ends as soon as a move reaches beta. Invariant: alpha <= beta in the returned Window.
 func (w Window) Constrain(s Rel) (c Window, ok bool) {
 	switch {
 	case Less(Score(s), Score(w.alpha)):
 		return w, true
-	case Less(Score(w.beta), Score(s)):
+	case !Less(Score(s), Score(w.beta)):
 		return Window{w.beta, w.beta}, false
 	default:
 		return Window{s, w.beta}, true
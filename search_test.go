@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -35,6 +37,390 @@ func TestCheckTerminal(t *testing.T) {
 	}
 }
 
+func TestIsRepetition(t *testing.T) {
+	for _, test := range []struct {
+		moves string
+		ply   int // the number of moves searched
+		want  bool
+	}{
+		{"g1f3 g8f6 f3g1 f6g8", 0, false},
+		{"g1f3 g8f6 f3g1 f6g8", 3, false},
+		{"g1f3 g8f6 f3g1 f6g8", 4, true},
+		{"g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1 f6g8", 0, true},
+		{"g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1 f6g8", 1, true},
+		{"g1f3 g8f6 f3g1 f6g8 e2e4 e7e5 g1f3 g8f6 f3g1 f6g8", 0, false}, // irreversible move
+		{"g1f3 g8f6 f3g1 f6g8 e2e4 e7e5 g1f3 g8f6 f3g1 f6g8", 4, true},
+		{"g1f3 g8f6 f3g1 f6g8 g1f3 g8f6", 2, false},
+	} {
+		pos, history, err := parseUCIPosition(strings.Fields("startpos moves "+test.moves), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := Search{ply: test.ply, path: history}
+		if got := s.isRepetition(pos); got != test.want {
+			t.Errorf("isRepetition(%v, ply %v): got %v, want %v", test.moves, test.ply, got, test.want)
+		}
+	}
+}
+
+func TestSearchRepetition(t *testing.T) {
+	// Returning the knight to g8 repeats the initial position for the third time.
+	pos, history, err := parseUCIPosition(strings.Fields("startpos moves g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range SearchPosition(context.Background(), pos, history, SearchOptions{Depth: 2}) {
+		if r.move.From == f6 && r.move.To == g8 && r.score.err != errRepetition {
+			t.Errorf("Nf6-g8: got %v, want %v", r.score, errRepetition)
+		}
+	}
+}
+
+func TestMultiPV(t *testing.T) {
+	defer func(tt *TransTable, n int) { hashTable, multiPV = tt, n }(hashTable, multiPV)
+	hashTable, multiPV = nil, 3
+	// Selective search depends on the ply of a node, so a move's score is only equal to the result
+	// of searching it alone when every node is searched to full depth.
+	defer setSelectivity(setSelectivity(false, false, false, false))
+	for _, fen := range []string{
+		"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3",
+		"r2q1rk1/ppp2ppp/2np1n2/2b1p1B1/2B1P1b1/2NP1N2/PPP2PPP/R2Q1RK1 w - - 6 8",
+	} {
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const depth = 3
+		rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: depth})
+		for _, r := range rs[:multiPV] {
+			if r.failLow {
+				t.Errorf("%v: %v has a fail-low score", fen, LongAlgebraic(r.move))
+			}
+			// The score of the move must equal the result of searching it alone.
+			want := SearchPosition(context.Background(), Make(pos, r.move), []Zobrist{pos.z}, SearchOptions{Depth: depth - 1})[0].score
+			if r.score != want {
+				t.Errorf("%v: %v: got %v, want %v", fen, LongAlgebraic(r.move), r.score, want)
+			}
+		}
+		for _, r := range rs[multiPV:] {
+			if Less(Score(rs[multiPV-1].score.Rel(pos.ToMove)), Score(r.score.Rel(pos.ToMove))) {
+				t.Errorf("%v: %v (%v) sorts after worse move %v (%v)", fen, LongAlgebraic(r.move), r.score, LongAlgebraic(rs[multiPV-1].move), rs[multiPV-1].score)
+			}
+		}
+	}
+}
+
+func TestQuiesce(t *testing.T) {
+	for _, test := range []struct {
+		fen      string
+		evasions bool
+		want     Rel
+	}{
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", false, Eval(mustParseFEN("4k3/8/8/8/8/8/8/3QK3 w - - 0 1")).Rel(White)},
+		{"4k3/8/4p3/3p4/8/8/8/3QK3 b - - 0 1", false, Eval(mustParseFEN("4k3/8/4p3/3p4/8/8/8/3QK3 b - - 0 1")).Rel(Black)},
+		{"R5k1/5ppp/8/8/8/8/8/6K1 b - - 1 1", false, Eval(mustParseFEN("R5k1/5ppp/8/8/8/8/8/6K1 b - - 1 1")).Rel(Black)},
+		{"R5k1/5ppp/8/8/8/8/8/6K1 b - - 1 1", true, Rel{err: errCheckmate}},
+	} {
+		s := Search{allowCutoff: true, evasions: test.evasions}
+		if got := s.quiesce(mustParseFEN(test.fen), openWindow); got != test.want {
+			t.Errorf("quiesce(%v, evasions %v): got %v, want %v", test.fen, test.evasions, got, test.want)
+		}
+	}
+}
+
+func TestSearchHorizon(t *testing.T) {
+	// The d5 pawn is defended, so capturing it loses the queen.
+	pos := mustParseFEN("4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1")
+	if rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: 1}); rs[0].move.To == d5 {
+		t.Errorf("got %v, want a move other than Qxd5", rs[0])
+	}
+}
+
+func TestPrincipalVariationSearch(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	defer setSelectivity(setSelectivity(false, false, false, false))
+	for _, fen := range []string{
+		"r2q1rk1/ppp2ppp/2np1n2/2b1p1B1/2B1P1b1/2NP1N2/PPP2PPP/R2Q1RK1 w - - 6 8",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1",
+	} {
+		pos := mustParseFEN(fen)
+		const depth = 4
+		var scores [2]Rel
+		var nodes [2]int
+		for i, pvs := range []bool{false, true} {
+			principalVariationSearch = pvs
+			s := newSearch(nil, depth)
+			var rs Results
+			for d := 1; d <= depth; d++ {
+				scores[i], rs = s.negamax(context.Background(), pos, rs, openWindow, d)
+			}
+			nodes[i] = s.info(depth).Nodes
+		}
+		// Principal variation search prunes no more than alpha-beta search, so the score must be the same.
+		if scores[1] != scores[0] {
+			t.Errorf("%v: got %v, want %v", fen, scores[1], scores[0])
+		}
+		t.Logf("%v: %v nodes without principal variation search, %v with", fen, nodes[0], nodes[1])
+		if nodes[1] >= nodes[0] {
+			t.Errorf("%v: principal variation search searched %v nodes, want fewer than %v", fen, nodes[1], nodes[0])
+		}
+	}
+}
+
+func TestSelectiveSearchNodes(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	defer setSelectivity(setSelectivity(false, false, false, false))
+	pos := mustParseFEN("r2q1rk1/ppp2ppp/2np1n2/2b1p1B1/2B1P1b1/2NP1N2/PPP2PPP/R2Q1RK1 w - - 6 8")
+	const depth = 5
+	without := searchNodes(pos, depth, true)
+	for _, test := range []struct {
+		name string
+		p    *bool
+	}{
+		{"null-move pruning", &nullMovePruning},
+		{"late move reductions", &lateMoveReductions},
+	} {
+		*test.p = true
+		with := searchNodes(pos, depth, true)
+		*test.p = false
+		t.Logf("%v: %v nodes without, %v with", test.name, without, with)
+		if with >= without {
+			t.Errorf("%v searched %v nodes, want fewer than %v", test.name, with, without)
+		}
+	}
+}
+
+func TestSelectiveSearchMate(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	defer setSelectivity(setSelectivity(false, false, false, false))
+	for _, test := range []struct {
+		fen  string
+		move Move
+		want checkmateError
+	}{
+		{"kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1", Move{From: a1, To: a6, Piece: Rook}, 3},
+		{"6k1/pp4p1/2p5/2bp4/8/P5Pb/1P3rrP/2BRRN1K b - - 0 1", Move{From: g2, To: g1, Piece: Rook}, 3},
+		{"r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1", Move{From: f8, To: c5, Piece: Bishop}, 5},
+	} {
+		pos := mustParseFEN(test.fen)
+		for _, f := range [][4]bool{
+			{true, false, false, false},
+			{false, true, false, false},
+			{false, false, true, false},
+			{false, false, false, true},
+			{true, true, true, true},
+		} {
+			setSelectivity(f[0], f[1], f[2], f[3])
+			rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: int(test.want) + 2})
+			if rs[0].move != test.move || rs[0].score.err != test.want {
+				t.Errorf("%v with pvs, null move, lmr, check extensions %v: got %v %v, want %v %v",
+					test.fen, f, LongAlgebraic(rs[0].move), rs[0].score, LongAlgebraic(test.move), test.want)
+			}
+		}
+	}
+}
+
+func TestNullMoveZugzwang(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	defer setSelectivity(setSelectivity(false, false, false, false))
+	// Pawn endings are often zugzwang, so passing the move must not be considered in them.
+	pos := mustParseFEN("8/8/1p6/1k6/8/1K6/1P6/8 w - - 0 1")
+	const depth = 6
+	without := searchNodes(pos, depth, true)
+	nullMovePruning = true
+	if with := searchNodes(pos, depth, true); with != without {
+		t.Errorf("got %v nodes with null-move pruning, want %v", with, without)
+	}
+}
+
+func TestSearchOnIteration(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = NewTransTable(1)
+	pos := mustParseFEN("r2q1rk1/ppp2ppp/2np1n2/2b1p1B1/2B1P1b1/2NP1N2/PPP2PPP/R2Q1RK1 w - - 6 8")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var infos []Info
+	rs := SearchPosition(ctx, pos, nil, SearchOptions{Depth: 100, OnIteration: func(info Info) {
+		if info.Bound != exactBound {
+			return
+		}
+		// Results are updated by the search as it continues.
+		if len(info.PV) == 0 || info.PV[0] != info.Best.move || info.Results[0].move != info.Best.move {
+			t.Errorf("depth %v: best move %v, PV %v, results %v", info.Depth, info.Best, info.PV, info.Results)
+		}
+		infos = append(infos, info)
+		if info.Depth == 4 {
+			cancel()
+		}
+	}})
+	if len(infos) != 4 {
+		t.Fatalf("got %v completed iterations, want 4", len(infos))
+	}
+	for i, info := range infos {
+		if info.Depth != i+1 {
+			t.Errorf("iteration %v: got depth %v", i+1, info.Depth)
+		}
+		if info.SelDepth < info.Depth {
+			t.Errorf("depth %v: got seldepth %v", info.Depth, info.SelDepth)
+		}
+		if i > 0 && info.Nodes <= infos[i-1].Nodes {
+			t.Errorf("depth %v: got %v nodes, no more than %v at depth %v", info.Depth, info.Nodes, infos[i-1].Nodes, i)
+		}
+	}
+	last := infos[len(infos)-1]
+	if last.Hashfull <= 0 {
+		t.Errorf("depth %v: got hashfull %v", last.Depth, last.Hashfull)
+	}
+	if rs[0].move != last.Best.move {
+		t.Errorf("got best move %v, want %v as last reported", LongAlgebraic(rs[0].move), LongAlgebraic(last.Best.move))
+	}
+}
+
+func TestSearchInterrupted(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = NewTransTable(1)
+	// Some of the limits stop the search while it searches again after failing outside of its aspiration window.
+	for nodes := 1000; nodes <= 20000; nodes += 500 {
+		hashTable.Clear()
+		var pv string
+		rs := SearchPosition(context.Background(), InitialPosition, nil, SearchOptions{Depth: 100, Nodes: nodes, OnIteration: func(info Info) {
+			if info.Bound == exactBound {
+				pv = info.Best.PV()
+			}
+		}})
+		if got := rs[0].PV(); got != pv {
+			t.Errorf("%v nodes: got %v, want %v as last completed", nodes, got, pv)
+		}
+	}
+}
+
+func TestSearchNodeLimit(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	pos := mustParseFEN("r2q1rk1/ppp2ppp/2np1n2/2b1p1B1/2B1P1b1/2NP1N2/PPP2PPP/R2Q1RK1 w - - 6 8")
+	var results [2]Results
+	var depths [2]int
+	var pvs [2]string
+	for i := range results {
+		results[i] = SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: 100, Nodes: 20000, OnIteration: func(info Info) {
+			if info.Nodes >= 20000 {
+				t.Errorf("depth %v completed after %v nodes, want fewer than 20000", info.Depth, info.Nodes)
+			}
+			depths[i] = info.Depth
+			if info.Bound == exactBound {
+				pvs[i] = info.Best.PV()
+			}
+		}})
+		// The best move is that of the last completed iteration.
+		if got := results[i][0].PV(); got != pvs[i] {
+			t.Errorf("got %v, want %v as last reported", got, pvs[i])
+		}
+	}
+	// The search must stop at the same point each time.
+	if depths[0] != depths[1] || fmt.Sprint(results[0]) != fmt.Sprint(results[1]) {
+		t.Errorf("got depth %v:\n%v\nthen depth %v:\n%v", depths[0], results[0], depths[1], results[1])
+	}
+}
+
+func TestMateSearch(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	for _, test := range []struct {
+		fen   string
+		mate  int
+		depth int
+		want  checkmateError
+	}{
+		{"kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1", 2, 3, 3},
+		{"kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1", 4, 3, 3},
+		{"kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1", 1, 1, 0},
+		// Check extensions find checkmates beyond the depth of the search.
+		{"r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1", 3, 3, 5},
+		// A longer checkmate found by extending checks is not reported.
+		{"r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1", 2, 3, 0},
+	} {
+		pos := mustParseFEN(test.fen)
+		var depth int
+		var pv string
+		rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Mate: test.mate, OnIteration: func(info Info) {
+			depth, pv = info.Depth, info.Best.PV()
+		}})
+		got, _ := rs[0].score.err.(checkmateError)
+		if got != test.want || depth != test.depth {
+			t.Errorf("%v, mate %v: got %v at depth %v, want %v at depth %v", test.fen, test.mate, rs[0].score, depth, checkmateError(test.want), test.depth)
+		}
+		if rs[0].PV() != pv {
+			t.Errorf("%v, mate %v: got %v, want %v as last reported", test.fen, test.mate, rs[0].PV(), pv)
+		}
+	}
+}
+
+func TestAspirationWindow(t *testing.T) {
+	for _, test := range []struct {
+		s    Rel
+		want Window
+	}{
+		{Rel{n: 40}, Window{Rel{n: 40 - aspirationDelta}, Rel{n: 40 + aspirationDelta}}},
+		{Rel{n: -3, err: errRepetition}, Window{Rel{n: -3 - aspirationDelta}, Rel{n: -3 + aspirationDelta}}},
+		{Rel{err: checkmateError(1)}, Window{Rel{err: checkmateError(3)}, openWindow.beta}},
+		{Rel{err: checkmateError(5)}, Window{Rel{err: checkmateError(7)}, Rel{err: checkmateError(3)}}},
+		{Rel{err: checkmateError(2)}, Window{openWindow.alpha, Rel{err: checkmateError(4)}}},
+		{Rel{err: checkmateError(6)}, Window{Rel{err: checkmateError(4)}, Rel{err: checkmateError(8)}}},
+	} {
+		if got := aspirationWindow(test.s); got != test.want {
+			t.Errorf("aspirationWindow(%v): got %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestAspirationSearch(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	hashTable = nil
+	defer func(b bool) { aspirationWindows = b }(aspirationWindows)
+	for _, fen := range []string{
+		"r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R w kq - 0 1",
+		"r1b1kb1r/pppp1ppp/5q2/4n3/3KP3/2N3PN/PPP4P/R1BQ1B1R b kq - 0 1",
+		"kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1",
+		"8/8/8/8/8/2k5/8/K1q5 w - - 0 1",
+	} {
+		pos := mustParseFEN(fen)
+		const depth = 4
+		var scores [2][]Rel
+		var fails int
+		for i, asp := range []bool{false, true} {
+			aspirationWindows = asp
+			SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: depth, OnIteration: func(info Info) {
+				if info.Bound != exactBound {
+					fails++
+					return
+				}
+				scores[i] = append(scores[i], info.Best.score.Rel(pos.ToMove))
+			}})
+		}
+		// A search within an aspiration window that does not fail has the same score as one within the open window.
+		if fmt.Sprint(scores[1]) != fmt.Sprint(scores[0]) {
+			t.Errorf("%v: got scores %v, want %v", fen, scores[1], scores[0])
+		}
+		// The score falls from a small advantage to checkmate, outside the first aspiration window.
+		if fen == "8/8/8/8/8/2k5/8/K1q5 w - - 0 1" && fails == 0 {
+			t.Errorf("%v: no failed search reported", fen)
+		}
+	}
+}
+
+func mustParseFEN(fen string) Position {
+	pos, err := ParseFEN(fen)
+	if err != nil {
+		panic(err)
+	}
+	return pos
+}
+
 func TestIsPseudoLegal(t *testing.T) {
 	for _, test := range []struct {
 		fen  string
@@ -210,6 +596,7 @@ var relTests = []struct{ r, p, n Rel }{
 	{Rel{err: errStalemate}, Rel{err: errStalemate}, Rel{err: errStalemate}},
 	{Rel{err: errInsufficient}, Rel{err: errInsufficient}, Rel{err: errInsufficient}},
 	{Rel{err: errFiftyMove}, Rel{err: errFiftyMove}, Rel{err: errFiftyMove}},
+	{Rel{err: errRepetition}, Rel{err: errRepetition}, Rel{err: errRepetition}},
 	{materel(4), materel(5), materel(3)},
 	{materel(3), materel(4), materel(2)},
 	{materel(1), materel(2), Rel{err: errCheckmate}},
@@ -260,7 +647,7 @@ func TestConstrain(t *testing.T) {
 		{centwindow(-50, -30), materel(6), centwindow(-50, -30), true},
 		{centwindow(-50, -30), Rel{n: -100}, centwindow(-50, -30), true},
 		{centwindow(-50, -30), Rel{n: -35}, centwindow(-35, -30), true},
-		{centwindow(-50, -30), Rel{n: -30}, centwindow(-30, -30), true},
+		{centwindow(-50, -30), Rel{n: -30}, centwindow(-30, -30), false},
 		{centwindow(-50, -30), Rel{n: 0}, centwindow(-30, -30), false},
 		{centwindow(-50, -30), Rel{err: errStalemate}, centwindow(-30, -30), false},
 		{centwindow(-50, -30), materel(5), centwindow(-30, -30), false},
@@ -269,7 +656,7 @@ func TestConstrain(t *testing.T) {
 		{centwindow(-20, 10), Rel{n: -30}, centwindow(-20, 10), true},
 		{centwindow(-20, 10), Rel{n: 0}, centwindow(0, 10), true},
 		{centwindow(-20, 10), Rel{err: errStalemate}, Window{Rel{err: errStalemate}, Rel{n: 10}}, true},
-		{centwindow(-20, 10), Rel{n: 10}, centwindow(10, 10), true},
+		{centwindow(-20, 10), Rel{n: 10}, centwindow(10, 10), false},
 		{centwindow(-20, 10), Rel{n: 30}, centwindow(10, 10), false},
 		{centwindow(-20, 10), materel(5), centwindow(10, 10), false},
 
@@ -277,7 +664,7 @@ func TestConstrain(t *testing.T) {
 		{centwindow(20, 100), Rel{err: errStalemate}, centwindow(20, 100), true},
 		{centwindow(20, 100), Rel{n: 0}, centwindow(20, 100), true},
 		{centwindow(20, 100), Rel{n: 60}, centwindow(60, 100), true},
-		{centwindow(20, 100), Rel{n: 100}, centwindow(100, 100), true},
+		{centwindow(20, 100), Rel{n: 100}, centwindow(100, 100), false},
 		{centwindow(20, 100), Rel{n: 120}, centwindow(100, 100), false},
 		{centwindow(20, 100), materel(5), centwindow(100, 100), false},
 
@@ -285,7 +672,7 @@ func TestConstrain(t *testing.T) {
 		{Window{materel(4), Rel{n: 5}}, materel(6), Window{materel(6), Rel{n: 5}}, true},
 		{Window{materel(4), Rel{n: 5}}, Rel{n: -800}, centwindow(-800, 5), true},
 		{Window{materel(4), Rel{n: 5}}, Rel{err: errStalemate}, Window{Rel{err: errStalemate}, Rel{n: 5}}, true},
-		{Window{materel(4), Rel{n: 5}}, Rel{n: 5}, centwindow(5, 5), true},
+		{Window{materel(4), Rel{n: 5}}, Rel{n: 5}, centwindow(5, 5), false},
 		{Window{materel(4), Rel{n: 5}}, Rel{n: 800}, centwindow(5, 5), false},
 		{Window{materel(4), Rel{n: 5}}, materel(5), centwindow(5, 5), false},
 
@@ -293,14 +680,14 @@ func TestConstrain(t *testing.T) {
 		{Window{Rel{n: 5}, materel(5)}, Rel{n: -800}, Window{Rel{n: 5}, materel(5)}, true},
 		{Window{Rel{n: 5}, materel(5)}, Rel{err: errStalemate}, Window{Rel{n: 5}, materel(5)}, true},
 		{Window{Rel{n: 5}, materel(5)}, Rel{n: 15}, Window{Rel{n: 15}, materel(5)}, true},
-		{Window{Rel{n: 5}, materel(5)}, materel(5), Window{materel(5), materel(5)}, true},
+		{Window{Rel{n: 5}, materel(5)}, materel(5), Window{materel(5), materel(5)}, false},
 		{Window{Rel{n: 5}, materel(5)}, materel(1), Window{materel(5), materel(5)}, false},
 
 		{Window{materel(8), materel(5)}, materel(2), Window{materel(8), materel(5)}, true},
 		{Window{materel(8), materel(5)}, materel(24), Window{materel(24), materel(5)}, true},
 		{Window{materel(8), materel(5)}, Rel{n: 50}, Window{Rel{n: 50}, materel(5)}, true},
 		{Window{materel(8), materel(5)}, materel(25), Window{materel(25), materel(5)}, true},
-		{Window{materel(8), materel(5)}, materel(5), Window{materel(5), materel(5)}, true},
+		{Window{materel(8), materel(5)}, materel(5), Window{materel(5), materel(5)}, false},
 		{Window{materel(8), materel(5)}, materel(3), Window{materel(5), materel(5)}, false},
 	} {
 		if gotc, gotok := test.w.Constrain(test.n); gotc != test.c || gotok != test.ok {
@@ -336,7 +723,7 @@ func BenchmarkSearchPosition(b *testing.B) {
 	}
 	ctx := context.Background()
 	for i := 0; i < b.N; i++ {
-		SearchPosition(ctx, pos, 2)
+		SearchPosition(ctx, pos, nil, SearchOptions{Depth: 2})
 	}
 }
 
@@ -369,3 +756,10 @@ func BenchmarkSortFor(b *testing.B) {
 		rs.SortFor(White)
 	}
 }
+
+// setSelectivity sets whether to employ each selective search technique and returns the previous settings.
+func setSelectivity(pvs, nullMove, lmr, checkExt bool) (bool, bool, bool, bool) {
+	p, n, l, c := principalVariationSearch, nullMovePruning, lateMoveReductions, checkExtensions
+	principalVariationSearch, nullMovePruning, lateMoveReductions, checkExtensions = pvs, nullMove, lmr, checkExt
+	return p, n, l, c
+}
@@ -0,0 +1,57 @@
+package main
+
+// seeValue holds the value of each type of Piece in static exchange evaluation, in centipawns.
+// The king's value exceeds that of any material it could win, so that it never captures onto a defended square.
+var seeValue = [...]int{None: 0, Pawn: 100, Knight: 320, Bishop: 330, Rook: 500, Queen: 900, King: 20000}
+
+// SEE returns the static exchange evaluation of m in pos: the material, in centipawns, that the side to move
+// gains by m and the sequence of captures on m.To that may follow, assuming that each side captures
+// with its least valuable piece and may decline to continue the exchange at any point.
+// Sliding pieces are included as earlier captures reveal them. Pins and checks are not considered.
+func SEE(pos Position, m Move) int {
+	var gain [32]int
+	occ := pos.b[White][All] | pos.b[Black][All]
+	gain[0] = seeValue[m.CapturePiece]
+	if m.EP {
+		// The captured pawn is beside the destination square.
+		occ &^= Square(m.From.Rank()<<3 | m.To.File()).Board()
+	}
+	piece := m.Piece
+	if m.IsPromotion() {
+		gain[0] += seeValue[m.PromotePiece] - seeValue[Pawn]
+		piece = m.PromotePiece
+	}
+	from := m.From.Board()
+	c := pos.ToMove
+	d := 0
+	for from != 0 {
+		d++
+		// Speculatively, the opponent captures piece.
+		gain[d] = seeValue[piece] - gain[d-1]
+		occ &^= from
+		c ^= 1
+		from, piece = leastValuableAttacker(pos, m.To, c, occ)
+	}
+	for d--; d > 0; d-- {
+		// The side to capture at d-1 declines if it would lose by capturing.
+		if gain[d] > -gain[d-1] {
+			gain[d-1] = -gain[d]
+		}
+	}
+	return gain[0]
+}
+
+// leastValuableAttacker returns the Board of the least valuable piece of c among the occupied squares occ
+// that attacks s, and the type of the piece, or 0 and None if there is none.
+func leastValuableAttacker(pos Position, s Square, c Color, occ Board) (Board, Piece) {
+	a := attackers(pos, s, c, occ) & occ
+	if a == 0 {
+		return 0, None
+	}
+	for p := Pawn; p <= King; p++ {
+		if b := a & pos.b[c][p]; b != 0 {
+			return LS1B(b), p
+		}
+	}
+	return 0, None
+}
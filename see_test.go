@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	for _, test := range []struct {
+		fen  string
+		move string
+		want int
+	}{
+		// undefended pawn
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		// x-ray attackers behind the rook and bishop
+		{"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", -220},
+		// doubled rooks
+		{"4k3/4r3/4r3/8/8/4R3/4R3/4K3 w - - 0 1", "e3e6", 500},
+		{"4k3/4r3/4r3/8/8/4R3/8/4K3 w - - 0 1", "e3e6", 0},
+		// losing capture
+		{"4k3/8/3p4/4n3/8/8/8/4QK2 w - - 0 1", "e1e5", -580},
+		// the queen recaptures only if the rook is undefended
+		{"4k3/8/3q4/4n3/8/8/8/4RK2 w - - 0 1", "e1e5", -180},
+		{"4k3/8/3q4/4n3/8/8/1B6/4RK2 w - - 0 1", "e1e5", 320},
+		// en passant
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		{"4k3/2b5/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 0},
+		// capturing en passant reveals the rook behind the captured pawn
+		{"3rk3/8/8/3pP3/8/8/8/3RK3 w - d6 0 1", "e5d6", 100},
+		{"3rk3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 0},
+		// promotion
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7b8q", 1120},
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7a8q", 800},
+		// the king may only recapture on an undefended square
+		{"4k3/8/8/8/8/8/3r4/3RK3 b - - 0 1", "d2d1", 0},
+		{"3rk3/8/8/8/8/8/3r4/3RK3 b - - 0 1", "d2d1", 500},
+		// a capture that wins material despite the recapture
+		{"4k3/8/8/8/8/8/3r4/R2QK3 b - - 0 1", "d2d1", 400},
+		// not a capture
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "a1a7", 0},
+		{"4k3/p7/8/8/8/8/8/1R2K3 w - - 0 1", "b1b6", -500},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ParseCoordinate(pos, test.move)
+		if err != nil {
+			t.Fatalf("%v: %v", test.fen, err)
+		}
+		if got := SEE(pos, m); got != test.want {
+			t.Errorf("SEE(%v, %v): got %v, want %v", test.fen, test.move, got, test.want)
+		}
+	}
+}
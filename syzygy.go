@@ -0,0 +1,991 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Syzygy is a tablebase of the Syzygy endgame tables in a set of directories.
+// Its WDL tables (.rtbw) give the outcome of each position, and its DTZ tables (.rtbz) the distance to the next
+// capture or pawn move that preserves it. Each table is read from its file when it is first probed.
+type Syzygy struct {
+	// wdl and dtz hold the tables by the material of the stronger side followed by the other's, such as "KRvK".
+	wdl, dtz map[string]*syzygyTable
+
+	// pieces is the greatest number of pieces of any WDL table.
+	pieces int
+}
+
+// Syzygy file extensions and the magic numbers that begin their files.
+const (
+	syzygyWDLExt   = ".rtbw"
+	syzygyDTZExt   = ".rtbz"
+	syzygyWDLMagic = 0x5d23e871
+	syzygyDTZMagic = 0xa50c66d7
+)
+
+// OpenSyzygy returns a Syzygy of the tables in the directories of path, which is separated as the PATH environment variable is.
+func OpenSyzygy(path string) (*Syzygy, error) {
+	tb := &Syzygy{wdl: make(map[string]*syzygyTable), dtz: make(map[string]*syzygyTable)}
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if ext != syzygyWDLExt && ext != syzygyDTZExt {
+				continue
+			}
+			material := strings.TrimSuffix(e.Name(), ext)
+			t, ok := newSyzygyTable(filepath.Join(dir, e.Name()), material, ext == syzygyDTZExt)
+			if !ok {
+				continue
+			}
+			if t.dtz {
+				tb.dtz[material] = t
+				continue
+			}
+			tb.wdl[material] = t
+			if t.pieceCount > tb.pieces {
+				tb.pieces = t.pieceCount
+			}
+		}
+	}
+	if len(tb.wdl) == 0 {
+		return nil, fmt.Errorf("no Syzygy tables in %v", path)
+	}
+	return tb, nil
+}
+
+func (tb *Syzygy) maxPieces() int { return tb.pieces }
+
+func (tb *Syzygy) probeWDL(pos Position) (wdl, bool) {
+	w, st := tb.search(pos, false)
+	return w, st != probeFail
+}
+
+func (tb *Syzygy) probeDTZ(pos Position) (int, bool) {
+	w, st := tb.search(pos, true)
+	switch {
+	case st == probeFail:
+		return 0, false
+	case w == wdlDraw:
+		// DTZ tables do not hold draws.
+		return 0, true
+	case st == probeZeroing:
+		// The table may hold any value for a position whose best move resets the counter.
+		return dtzBeforeZeroing(w), true
+	}
+	dtz, st := tb.probeTable(pos, true, w)
+	switch st {
+	case probeFail:
+		return 0, false
+	case probeOK:
+		if w == wdlCursedWin || w == wdlBlessedLoss {
+			dtz += 100
+		}
+		if w < 0 {
+			dtz = -dtz
+		}
+		return dtz, true
+	}
+
+	// The table holds the positions with the other side to move, so find the move that best preserves the outcome.
+	best := 0xffff
+	for _, m := range LegalMoves(pos) {
+		child := Make(pos, m)
+		zeroing := m.IsCapture() || m.Piece == Pawn
+		var dtz int
+		if zeroing {
+			cw, st := tb.search(child, false)
+			if st == probeFail {
+				return 0, false
+			}
+			dtz = -dtzBeforeZeroing(cw)
+		} else {
+			d, ok := tb.probeDTZ(child)
+			if !ok {
+				return 0, false
+			}
+			dtz = -d
+		}
+		if dtz == 1 && IsCheck(child) && IsMate(child) {
+			best = 1
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < best && sign(dtz) == sign(int(w)) {
+			best = dtz
+		}
+	}
+	if best == 0xffff {
+		// The side to move is checkmated.
+		return -1, true
+	}
+	return best, true
+}
+
+// sign returns -1, 0, or 1 as n is negative, zero, or positive.
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// A probeState describes the outcome of a probe besides its value.
+type probeState int
+
+const (
+	probeOK probeState = iota
+
+	// probeFail indicates that a table was missing or invalid.
+	probeFail
+
+	// probeZeroing indicates that the best move resets the fifty-move counter.
+	probeZeroing
+
+	// probeChangeSTM indicates that the DTZ table holds the positions with the other side to move.
+	probeChangeSTM
+)
+
+// search returns the outcome of pos for the side to move, searching its captures, and its pawn moves if pawnMoves is set,
+// since tables do not hold positions with en passant rights and may hold any value for a position whose best move resets the counter.
+func (tb *Syzygy) search(pos Position, pawnMoves bool) (wdl, probeState) {
+	best := wdlLoss
+	moves := LegalMoves(pos)
+	var n int
+	for _, m := range moves {
+		if !m.IsCapture() && (!pawnMoves || m.Piece != Pawn) {
+			continue
+		}
+		n++
+		w, st := tb.search(Make(pos, m), false)
+		if st == probeFail {
+			return wdlDraw, probeFail
+		}
+		if w = -w; w > best {
+			best = w
+			if w == wdlWin {
+				return w, probeZeroing
+			}
+		}
+	}
+
+	// If every legal move was searched, the table need not be consulted.
+	noMoreMoves := n > 0 && n == len(moves)
+	w := best
+	if !noMoreMoves {
+		v, st := tb.probeTable(pos, false, wdlDraw)
+		if st == probeFail {
+			return wdlDraw, probeFail
+		}
+		w = wdl(v)
+	}
+	if best >= w {
+		if best > wdlDraw || noMoreMoves {
+			return best, probeZeroing
+		}
+		return best, probeOK
+	}
+	return w, probeOK
+}
+
+// probeTable returns the value that the WDL table, or the DTZ table if dtz is set, holds for pos.
+// A DTZ table is probed given w, the outcome of pos, and its value is in plies.
+func (tb *Syzygy) probeTable(pos Position, dtz bool, w wdl) (int, probeState) {
+	if PopCount(pos.b[White][All]|pos.b[Black][All]) == 2 {
+		return int(wdlDraw), probeOK
+	}
+	tables := tb.wdl
+	if dtz {
+		tables = tb.dtz
+	}
+	t, blackStronger := tables[syzygyMaterial(pos, White)], false
+	if t == nil {
+		t, blackStronger = tables[syzygyMaterial(pos, Black)], true
+	}
+	if t == nil || t.load() != nil {
+		return 0, probeFail
+	}
+
+	// Tables hold positions with the stronger side as White, and symmetric ones with White to move.
+	// Other positions are probed with the colors reversed and the board flipped.
+	stm := int(pos.ToMove)
+	var flipColor int
+	var flipSquares Square
+	if blackStronger || t.symmetric && pos.ToMove == Black {
+		stm ^= 1
+		flipColor, flipSquares = 8, 56
+	}
+
+	var squares [syzygyMaxPieces]Square
+	var pieces [syzygyMaxPieces]int
+	var n, leadPawns, file int
+	var lead Board
+	if t.hasPawns {
+		// Tables with pawns are split by the file of the leading pawn, the one nearest the edge and then lowest.
+		pc := t.pairs[0][0].pieces[0] ^ flipColor
+		lead = pos.b[pc>>3][Pawn]
+		for b := lead; b != 0; b &= b - 1 {
+			squares[n] = LS1BIndex(b) ^ flipSquares
+			n++
+		}
+		leadPawns = n
+		max := 0
+		for i := 1; i < leadPawns; i++ {
+			if syzygyMapPawns[squares[i]] > syzygyMapPawns[squares[max]] {
+				max = i
+			}
+		}
+		squares[0], squares[max] = squares[max], squares[0]
+		file = int(squares[0].File())
+		if file > 3 {
+			file = 7 - file
+		}
+	}
+	d := t.get(stm, file)
+	if dtz && int(d.flags&syzygySTM) != stm && !(t.symmetric && !t.hasPawns) {
+		return 0, probeChangeSTM
+	}
+
+	for b := (pos.b[White][All] | pos.b[Black][All]) &^ lead; b != 0; b &= b - 1 {
+		s := LS1BIndex(b)
+		c, p := pos.PieceOn(s)
+		squares[n] = s ^ flipSquares
+		pieces[n] = (int(c)<<3 | int(p)) ^ flipColor
+		n++
+	}
+	// Order the pieces as the table encodes them.
+	for i := leadPawns; i < n-1; i++ {
+		for j := i + 1; j < n; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	v := d.decompress(t.encode(d, squares[:n], leadPawns))
+	if !dtz {
+		return v - 2, probeOK
+	}
+	return t.mapDTZ(d, file, v, w), probeOK
+}
+
+// syzygyMaterial returns the material of pos as Syzygy names its tables, with c's pieces first.
+func syzygyMaterial(pos Position, c Color) string {
+	var sb strings.Builder
+	for i, c := range []Color{c, c ^ 1} {
+		if i > 0 {
+			sb.WriteByte('v')
+		}
+		for _, p := range []Piece{King, Queen, Rook, Bishop, Knight, Pawn} {
+			sb.WriteString(strings.Repeat(pieceLetter[p], PopCount(pos.b[c][p])))
+		}
+	}
+	return sb.String()
+}
+
+// syzygyMaxPieces is the greatest number of pieces of any Syzygy table.
+const syzygyMaxPieces = 7
+
+// A syzygyTable is the WDL or DTZ table of one material signature.
+type syzygyTable struct {
+	path string
+	dtz  bool
+
+	// pieceCount is the number of pieces, including the kings. hasUniquePieces reports whether either side
+	// has a piece other than its king of which it has no other. symmetric reports whether the sides have the same material.
+	pieceCount                           int
+	hasPawns, hasUniquePieces, symmetric bool
+
+	// pawnCount holds the number of pawns of the leading color, the one with fewer pawns if both have them, followed by the other's.
+	pawnCount [2]int
+
+	once sync.Once
+	err  error
+	data []byte
+
+	// pairs holds the encoding of the table for each side to move, when the sides differ in WDL tables,
+	// and each file of the leading pawn, in tables with pawns.
+	pairs [2][4]syzygyPairs
+
+	// dtzMap is the offset in data of the maps of DTZ values.
+	dtzMap int
+}
+
+// newSyzygyTable returns the table with the named material in the named file.
+// It reports false if the material is not a valid name of a table.
+func newSyzygyTable(path, material string, dtz bool) (*syzygyTable, bool) {
+	white, black, ok := strings.Cut(material, "v")
+	if !ok || len(material)-1 > syzygyMaxPieces {
+		return nil, false
+	}
+	t := &syzygyTable{path: path, dtz: dtz, pieceCount: len(material) - 1, symmetric: white == black}
+	var pawns [2]int
+	for c, side := range []string{white, black} {
+		if !strings.HasPrefix(side, "K") || strings.Trim(side[1:], "QRBNP") != "" {
+			return nil, false
+		}
+		for _, l := range "QRBNP" {
+			if strings.Count(side, string(l)) == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+		pawns[c] = strings.Count(side, "P")
+	}
+	t.hasPawns = pawns[White]+pawns[Black] > 0
+	t.pawnCount = pawns
+	if pawns[Black] > 0 && (pawns[White] == 0 || pawns[White] > pawns[Black]) {
+		t.pawnCount = [2]int{pawns[Black], pawns[White]}
+	}
+	return t, true
+}
+
+// get returns the encoding of t for side to move stm and leading pawn file.
+func (t *syzygyTable) get(stm, file int) *syzygyPairs {
+	if t.dtz {
+		stm = 0
+	}
+	if !t.hasPawns {
+		file = 0
+	}
+	return &t.pairs[stm][file]
+}
+
+// load reads t from its file, if it has not already, and returns any error in doing so.
+func (t *syzygyTable) load() error {
+	t.once.Do(func() {
+		data, err := os.ReadFile(t.path)
+		if err == nil {
+			err = t.parse(data)
+		}
+		if err != nil {
+			t.err = fmt.Errorf("%v: %v", t.path, err)
+		}
+	})
+	return t.err
+}
+
+var errSyzygyTruncated = errors.New("truncated Syzygy table")
+
+// Flags of each encoding of a table.
+const (
+	syzygySTM         = 1
+	syzygyMapped      = 2
+	syzygyWinPlies    = 4
+	syzygyLossPlies   = 8
+	syzygyWide        = 16
+	syzygySingleValue = 128
+)
+
+// parse sets up t to probe data, the contents of its file.
+func (t *syzygyTable) parse(data []byte) error {
+	magic := uint32(syzygyWDLMagic)
+	if t.dtz {
+		magic = syzygyDTZMagic
+	}
+	if len(data) < 6 || binary.LittleEndian.Uint32(data) != magic {
+		return errors.New("not a Syzygy table")
+	}
+	if (data[4]&2 != 0) != t.hasPawns {
+		return errors.New("Syzygy table does not match its name")
+	}
+	size := len(data)
+	// Decompression may read a few bytes beyond the last block.
+	t.data = append(data, make([]byte, 8)...)
+	data = t.data
+
+	sides := 1
+	if !t.dtz && !t.symmetric {
+		sides = 2
+	}
+	files := 1
+	if t.hasPawns {
+		files = 4
+	}
+	pp := t.hasPawns && t.pawnCount[1] > 0
+
+	off := 5
+	for f := 0; f < files; f++ {
+		if off+2+t.pieceCount > size {
+			return errSyzygyTruncated
+		}
+		// The order in which the groups of pieces are encoded, for each side.
+		order := [2][2]int{{int(data[off] & 0xf), 0xf}, {int(data[off] >> 4), 0xf}}
+		if pp {
+			order[0][1], order[1][1] = int(data[off+1]&0xf), int(data[off+1]>>4)
+			off++
+		}
+		off++
+		for k := 0; k < t.pieceCount; k++ {
+			for i := 0; i < sides; i++ {
+				t.pairs[i][f].pieces[k] = int(data[off+k] & 0xf)
+				if i > 0 {
+					t.pairs[i][f].pieces[k] = int(data[off+k] >> 4)
+				}
+			}
+		}
+		off += t.pieceCount
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].setGroups(t, order[i], f)
+		}
+	}
+	off += off & 1
+
+	var err error
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].file = data
+			if off, err = t.pairs[i][f].setSizes(data[:size], off); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.dtz {
+		t.dtzMap = off
+		for f := 0; f < files; f++ {
+			d := &t.pairs[0][f]
+			if d.flags&syzygyMapped == 0 {
+				continue
+			}
+			if d.flags&syzygyWide != 0 {
+				off += off & 1
+				for i := range d.mapIdx {
+					if off+2 > size {
+						return errSyzygyTruncated
+					}
+					d.mapIdx[i] = (off-t.dtzMap)/2 + 1
+					off += 2*int(binary.LittleEndian.Uint16(data[off:])) + 2
+				}
+			} else {
+				for i := range d.mapIdx {
+					if off >= size {
+						return errSyzygyTruncated
+					}
+					d.mapIdx[i] = off - t.dtzMap + 1
+					off += int(data[off]) + 1
+				}
+			}
+		}
+		off += off & 1
+	}
+
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].sparseIndex = off
+			off += 6 * t.pairs[i][f].sparseIndexSize
+		}
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].blockLength = off
+			off += 2 * t.pairs[i][f].blockLengthSize
+		}
+	}
+	if off > size {
+		return errSyzygyTruncated
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < sides; i++ {
+			off = (off + 0x3f) &^ 0x3f
+			t.pairs[i][f].data = off
+			if n := t.pairs[i][f].numBlocks * t.pairs[i][f].blockSize; n > 0 {
+				if off += n; off > size {
+					return errSyzygyTruncated
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mapDTZ returns the DTZ in plies of a position with outcome w, given the value v of its index in d, the encoding of file.
+func (t *syzygyTable) mapDTZ(d *syzygyPairs, file int, v int, w wdl) int {
+	if d.flags&syzygyMapped != 0 {
+		// The maps are ordered win, loss, cursed win, and blessed loss.
+		i := d.mapIdx[[...]int{1, 3, 0, 2, 0}[w-wdlLoss]] + v
+		if d.flags&syzygyWide != 0 {
+			v = int(binary.LittleEndian.Uint16(t.data[t.dtzMap+2*i:]))
+		} else {
+			v = int(t.data[t.dtzMap+i])
+		}
+	}
+	if w == wdlWin && d.flags&syzygyWinPlies == 0 || w == wdlLoss && d.flags&syzygyLossPlies == 0 ||
+		w == wdlCursedWin || w == wdlBlessedLoss {
+		// The value is in moves.
+		v *= 2
+	}
+	return v + 1
+}
+
+// syzygyPairs is an encoding of the values of a table, which are indexed by the squares of its pieces
+// and compressed by recursive pairing into Huffman-coded blocks. Offsets are into file, the contents of the table's file.
+type syzygyPairs struct {
+	file  []byte
+	flags byte
+
+	// pieces holds the pieces of the table in the order in which they are encoded, as 1 (pawn) to 6 (king) plus 8 for Black.
+	// Identical pieces form groups, of which groupLen holds the sizes, followed by 0, and groupIdx the multipliers of their indices.
+	pieces   [syzygyMaxPieces]int
+	groupLen [syzygyMaxPieces + 1]int
+	groupIdx [syzygyMaxPieces + 1]int
+
+	blockSize, span, numBlocks int
+	minSymLen                  int
+	lowestSym, btree           int
+	base64                     []uint64
+	symLen                     []int
+
+	sparseIndex, sparseIndexSize int
+	blockLength, blockLengthSize int
+	data                         int
+
+	// mapIdx holds the offsets plus one of the maps of DTZ values.
+	mapIdx [4]int
+}
+
+// setGroups sets the groups of d, whose pieces are set, given the order in which the leading group
+// and the remaining pawns are encoded, and the file of the leading pawn.
+func (d *syzygyPairs) setGroups(t *syzygyTable, order [2]int, file int) {
+	// The leading group is the pawns of the leading color, or the kings and, if any, a unique piece.
+	firstLen := 2
+	switch {
+	case t.hasPawns:
+		firstLen = 0
+	case t.hasUniquePieces:
+		firstLen = 3
+	}
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		if firstLen--; firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next, free := 1, 64-d.groupLen[0]
+	if pp {
+		next, free = 2, free-d.groupLen[1]
+	}
+	idx := 1
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch k {
+		case order[0]:
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= syzygyLeadPawnsSize[d.groupLen[0]][file]
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case order[1]:
+			d.groupIdx[1] = idx
+			idx *= syzygyBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		default:
+			d.groupIdx[next] = idx
+			idx *= syzygyBinomial[d.groupLen[next]][free]
+			free -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// size returns the number of indices of d.
+func (d *syzygyPairs) size() int {
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	return d.groupIdx[n]
+}
+
+// setSizes sets up the decompression of d from the header at offset off in data and returns the offset following it.
+func (d *syzygyPairs) setSizes(data []byte, off int) (int, error) {
+	if off+2 > len(data) {
+		return 0, errSyzygyTruncated
+	}
+	d.flags = data[off]
+	if d.flags&syzygySingleValue != 0 {
+		// Every index holds the same value.
+		d.minSymLen = int(data[off+1])
+		return off + 2, nil
+	}
+	if off+10 > len(data) {
+		return 0, errSyzygyTruncated
+	}
+	d.blockSize = 1 << data[off+1]
+	d.span = 1 << data[off+2]
+	d.sparseIndexSize = (d.size() + d.span - 1) / d.span
+	padding := int(data[off+3])
+	d.numBlocks = int(binary.LittleEndian.Uint32(data[off+4:]))
+	d.blockLengthSize = d.numBlocks + padding
+	maxSymLen, minSymLen := int(data[off+8]), int(data[off+9])
+	off += 10
+
+	// Symbols are canonical Huffman codes, of which lowestSym holds the lowest of each length.
+	// base64 holds the lowest code of each length left-aligned in 64 bits, so that a longer code is numerically lower.
+	n := maxSymLen - minSymLen + 1
+	if n < 1 || off+2*n+2 > len(data) {
+		return 0, errSyzygyTruncated
+	}
+	d.minSymLen, d.lowestSym = minSymLen, off
+	d.base64 = make([]uint64, n)
+	for i := n - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowest(i)) - uint64(d.lowest(i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= 64 - i - minSymLen
+	}
+	off += 2 * n
+
+	// Each symbol stands for a value or a pair of symbols, as given by btree.
+	syms := int(binary.LittleEndian.Uint16(data[off:]))
+	off += 2
+	if off+3*syms > len(data) {
+		return 0, errSyzygyTruncated
+	}
+	d.btree = off
+	d.symLen = make([]int, syms)
+	visited := make([]bool, syms)
+	for s := range d.symLen {
+		if !visited[s] {
+			l, ok := d.setSymLen(s, visited)
+			if !ok {
+				return 0, errors.New("invalid Syzygy table")
+			}
+			d.symLen[s] = l
+		}
+	}
+	return off + 3*syms + syms&1, nil
+}
+
+// setSymLen returns the number of values for which symbol s stands, less one, and sets those of the symbols it stands for.
+// It reports false if s stands for a symbol that does not exist.
+func (d *syzygyPairs) setSymLen(s int, visited []bool) (int, bool) {
+	visited[s] = true
+	r := d.right(s)
+	if r == 0xfff {
+		return 0, true
+	}
+	l := d.left(s)
+	for _, c := range []int{l, r} {
+		if c >= len(visited) {
+			return 0, false
+		}
+		if !visited[c] {
+			n, ok := d.setSymLen(c, visited)
+			if !ok {
+				return 0, false
+			}
+			d.symLen[c] = n
+		}
+	}
+	return d.symLen[l] + d.symLen[r] + 1, true
+}
+
+// lowest returns the lowest symbol with a code of minSymLen+i bits.
+func (d *syzygyPairs) lowest(i int) int {
+	return int(binary.LittleEndian.Uint16(d.file[d.lowestSym+2*i:]))
+}
+
+// left and right return the symbols of the pair for which s stands. The left symbol of a value is the value.
+func (d *syzygyPairs) left(s int) int {
+	lr := d.file[d.btree+3*s:]
+	return int(lr[1]&0xf)<<8 | int(lr[0])
+}
+
+func (d *syzygyPairs) right(s int) int {
+	lr := d.file[d.btree+3*s:]
+	return int(lr[2])<<4 | int(lr[1]>>4)
+}
+
+// blockLen returns the number of values in block, less one.
+func (d *syzygyPairs) blockLen(block int) int {
+	return int(binary.LittleEndian.Uint16(d.file[d.blockLength+2*block:]))
+}
+
+// decompress returns the value of index idx.
+func (d *syzygyPairs) decompress(idx int) int {
+	if d.flags&syzygySingleValue != 0 {
+		return d.minSymLen
+	}
+
+	// The sparse index gives the block and offset within it of every spanth index, from which the block holding idx is found.
+	e := d.sparseIndex + 6*(idx/d.span)
+	block := int(binary.LittleEndian.Uint32(d.file[e:]))
+	offset := int(binary.LittleEndian.Uint16(d.file[e+4:])) + idx%d.span - d.span/2
+	for offset < 0 {
+		block--
+		offset += d.blockLen(block) + 1
+	}
+	for offset > d.blockLen(block) {
+		offset -= d.blockLen(block) + 1
+		block++
+	}
+
+	// Decode the symbols of the block until reaching the one that stands for the value at offset.
+	p := d.data + block*d.blockSize
+	buf := binary.BigEndian.Uint64(d.file[p:])
+	p += 8
+	bits := 64
+	var sym int
+	for {
+		l := 0
+		for buf < d.base64[l] {
+			l++
+		}
+		sym = int(uint16((buf-d.base64[l])>>(64-l-d.minSymLen)) + uint16(d.lowest(l)))
+		if offset < d.symLen[sym]+1 {
+			break
+		}
+		offset -= d.symLen[sym] + 1
+		l += d.minSymLen
+		buf <<= l
+		if bits -= l; bits <= 32 {
+			bits += 32
+			buf |= uint64(binary.BigEndian.Uint32(d.file[p:])) << (64 - bits)
+			p += 4
+		}
+	}
+
+	// Expand the symbol into the pair that holds the value at offset, until reaching a value.
+	for d.symLen[sym] != 0 {
+		if l := d.left(sym); offset < d.symLen[l]+1 {
+			sym = l
+		} else {
+			offset -= d.symLen[l] + 1
+			sym = d.right(sym)
+		}
+	}
+	return d.left(sym)
+}
+
+// encode returns the index in d of the position with the pieces of d.pieces on squares, of which the first leadPawns are the leading pawns.
+// Positions that are equivalent by symmetry have the same index. The order of squares is modified.
+func (t *syzygyTable) encode(d *syzygyPairs, squares []Square, leadPawns int) int {
+	if squares[0].File() > 3 {
+		for i := range squares {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx int
+	if t.hasPawns {
+		idx = syzygyLeadPawnIdx[leadPawns][squares[0]]
+		others := squares[1:leadPawns]
+		sort.SliceStable(others, func(i, j int) bool { return syzygyMapPawns[others[i]] < syzygyMapPawns[others[j]] })
+		for i := 1; i < leadPawns; i++ {
+			idx += syzygyBinomial[i][syzygyMapPawns[squares[i]]]
+		}
+	} else {
+		// Without pawns, the board may also be flipped vertically and diagonally,
+		// so that the first piece is in the a1-d1-d4 triangle, and the first not on the a1-h8 diagonal is below it.
+		if squares[0].Rank() > 3 {
+			for i := range squares {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			if o := offA1H8(squares[i]); o != 0 {
+				if o > 0 {
+					for j := i; j < len(squares); j++ {
+						squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+					}
+				}
+				break
+			}
+		}
+		if t.hasUniquePieces {
+			var adjust1, adjust2 int
+			if squares[1] > squares[0] {
+				adjust1++
+			}
+			if squares[2] > squares[0] {
+				adjust2++
+			}
+			if squares[2] > squares[1] {
+				adjust2++
+			}
+			s0, s1, s2 := int(squares[0]), int(squares[1]), int(squares[2])
+			r0, r1, r2 := int(squares[0].Rank()), int(squares[1].Rank()), int(squares[2].Rank())
+			switch {
+			case offA1H8(squares[0]) != 0:
+				idx = (syzygyMapA1D1D4[s0]*63+s1-adjust1)*62 + s2 - adjust2
+			case offA1H8(squares[1]) != 0:
+				idx = (6*63+r0*28+syzygyMapB1H1H7[s1])*62 + s2 - adjust2
+			case offA1H8(squares[2]) != 0:
+				idx = 6*63*62 + 4*28*62 + r0*7*28 + (r1-adjust1)*28 + syzygyMapB1H1H7[s2]
+			default:
+				idx = 6*63*62 + 4*28*62 + 4*7*28 + r0*7*6 + (r1-adjust1)*6 + r2 - adjust2
+			}
+		} else {
+			idx = syzygyMapKK[syzygyMapA1D1D4[squares[0]]][squares[1]]
+		}
+	}
+	idx *= d.groupIdx[0]
+
+	// Each remaining group is encoded as a combination of the squares not taken by the groups before it,
+	// which for the other side's pawns excludes the first and last ranks.
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	g := d.groupLen[0]
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[g : g+d.groupLen[next]]
+		sort.Slice(group, func(i, j int) bool { return group[i] < group[j] })
+		n := 0
+		for i, s := range group {
+			v := int(s)
+			for _, prev := range squares[:g] {
+				if s > prev {
+					v--
+				}
+			}
+			if remainingPawns {
+				v -= 8
+			}
+			n += syzygyBinomial[i+1][v]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		g += d.groupLen[next]
+	}
+	return idx
+}
+
+// offA1H8 returns the distance of s above the a1-h8 diagonal, which is negative below it.
+func offA1H8(s Square) int { return int(s.Rank()) - int(s.File()) }
+
+// Tables for encoding the squares of pieces as indices.
+var (
+	// syzygyMapB1H1H7 numbers the squares below the a1-h8 diagonal, and syzygyMapA1D1D4 those of the a1-d1-d4 triangle,
+	// followed by its diagonal.
+	syzygyMapB1H1H7 [64]int
+	syzygyMapA1D1D4 [64]int
+
+	// syzygyMapKK numbers the 462 placements of two kings that are not adjacent, by the number of the first in the a1-d1-d4 triangle
+	// and the square of the second, which is not above the diagonal if the first is on it.
+	syzygyMapKK [10][64]int
+
+	// syzygyBinomial holds the binomial coefficients, the number of ways to choose k of n squares at [k][n].
+	syzygyBinomial [syzygyMaxPieces + 1][64]int
+
+	// syzygyMapPawns numbers the squares of the second through seventh ranks in descending order of precedence as the leading pawn.
+	// syzygyLeadPawnIdx and syzygyLeadPawnsSize hold the first index of each number of leading pawns with the leading one on a square,
+	// and the number of indices of all with it on a file.
+	syzygyMapPawns      [64]int
+	syzygyLeadPawnIdx   [syzygyMaxPieces + 1][64]int
+	syzygyLeadPawnsSize [syzygyMaxPieces + 1][4]int
+)
+
+func init() {
+	var code int
+	for s := a1; s <= h8; s++ {
+		if offA1H8(s) < 0 {
+			syzygyMapB1H1H7[s] = code
+			code++
+		}
+	}
+
+	code = 0
+	var diagonal []Square
+	for s := a1; s <= d4; s++ {
+		switch {
+		case s.File() > 3:
+		case offA1H8(s) < 0:
+			syzygyMapA1D1D4[s] = code
+			code++
+		case offA1H8(s) == 0:
+			diagonal = append(diagonal, s)
+		}
+	}
+	for _, s := range diagonal {
+		syzygyMapA1D1D4[s] = code
+		code++
+	}
+
+	code = 0
+	type kings struct {
+		i int
+		s Square
+	}
+	var bothOnDiagonal []kings
+	for i := 0; i < 10; i++ {
+		for s1 := a1; s1 <= d4; s1++ {
+			if syzygyMapA1D1D4[s1] != i || i == 0 && s1 != b1 {
+				continue
+			}
+			for s2 := a1; s2 <= h8; s2++ {
+				df, dr := int(s1.File())-int(s2.File()), int(s1.Rank())-int(s2.Rank())
+				switch {
+				case df >= -1 && df <= 1 && dr >= -1 && dr <= 1:
+					// adjacent kings
+				case offA1H8(s1) == 0 && offA1H8(s2) > 0:
+				case offA1H8(s1) == 0 && offA1H8(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, kings{i, s2})
+				default:
+					syzygyMapKK[i][s2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, k := range bothOnDiagonal {
+		syzygyMapKK[k.i][k.s] = code
+		code++
+	}
+
+	syzygyBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k <= syzygyMaxPieces && k <= n; k++ {
+			if k > 0 {
+				syzygyBinomial[k][n] += syzygyBinomial[k-1][n-1]
+			}
+			if k < n {
+				syzygyBinomial[k][n] += syzygyBinomial[k][n-1]
+			}
+		}
+	}
+
+	// Pawns nearer the edge, and then lower, take precedence, so that the other leading pawns
+	// are on the squares with lower numbers than the leading one.
+	available := 47
+	for n := 1; n <= 5; n++ {
+		for f := 0; f < 4; f++ {
+			idx := 0
+			for r := 1; r <= 6; r++ {
+				s := Square(8*r + f)
+				if n == 1 {
+					syzygyMapPawns[s] = available
+					syzygyMapPawns[s^7] = available - 1
+					available -= 2
+				}
+				syzygyLeadPawnIdx[n][s] = idx
+				idx += syzygyBinomial[n-1][syzygyMapPawns[s]]
+			}
+			syzygyLeadPawnsSize[n][f] = idx
+		}
+	}
+}
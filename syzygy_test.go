@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// symmetries holds the transformations of a square by which positions without pawns are equivalent.
+var symmetries = []func(Square) Square{
+	func(s Square) Square { return s },
+	func(s Square) Square { return s ^ 7 },
+	func(s Square) Square { return s ^ 56 },
+	func(s Square) Square { return s ^ 63 },
+	func(s Square) Square { return (s>>3 | s<<3) & 63 },
+	func(s Square) Square { return (s>>3|s<<3)&63 ^ 7 },
+	func(s Square) Square { return (s>>3|s<<3)&63 ^ 56 },
+	func(s Square) Square { return (s>>3|s<<3)&63 ^ 63 },
+}
+
+func TestSyzygyEncodeUniquePieces(t *testing.T) {
+	tb, _ := newSyzygyTable("", "KQvK", false)
+	d := &tb.pairs[0][0]
+	d.pieces = [syzygyMaxPieces]int{6, 5, 14}
+	d.setGroups(tb, [2]int{0, 0xf}, 0)
+	if got := d.size(); got != 31332 {
+		t.Fatalf("got size %v, want 31332", got)
+	}
+	// Equivalent positions have the same index, and there are as many indices as classes of equivalent positions.
+	seen := make([]bool, d.size())
+	for s0 := a1; s0 <= h8; s0++ {
+		for s1 := a1; s1 <= h8; s1++ {
+			for s2 := a1; s2 <= h8; s2++ {
+				if s0 == s1 || s0 == s2 || s1 == s2 {
+					continue
+				}
+				idx := tb.encode(d, []Square{s0, s1, s2}, 0)
+				if idx < 0 || idx >= len(seen) {
+					t.Fatalf("%v %v %v: got index %v, want [0, %v)", s0, s1, s2, idx, len(seen))
+				}
+				seen[idx] = true
+				for _, f := range symmetries[1:] {
+					if got := tb.encode(d, []Square{f(s0), f(s1), f(s2)}, 0); got != idx {
+						t.Fatalf("%v %v %v: got index %v, want %v as for %v %v %v", f(s0), f(s1), f(s2), got, idx, s0, s1, s2)
+					}
+				}
+			}
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Errorf("index %v not encoded", i)
+		}
+	}
+}
+
+func TestSyzygyEncodeKings(t *testing.T) {
+	tb, _ := newSyzygyTable("", "KRRvK", false)
+	d := &tb.pairs[0][0]
+	d.pieces = [syzygyMaxPieces]int{6, 14, 4, 4}
+	d.setGroups(tb, [2]int{0, 0xf}, 0)
+	if want := 462 * 62 * 61 / 2; d.size() != want {
+		t.Fatalf("got size %v, want %v", d.size(), want)
+	}
+	seen := make([]bool, d.size())
+	for s0 := a1; s0 <= h8; s0++ {
+		for s1 := a1; s1 <= h8; s1++ {
+			df, dr := int(s0.File())-int(s1.File()), int(s0.Rank())-int(s1.Rank())
+			if df*df <= 1 && dr*dr <= 1 {
+				continue
+			}
+			for s2 := a1; s2 <= h8; s2++ {
+				for s3 := s2 + 1; s3 <= h8; s3++ {
+					if s2 == s0 || s2 == s1 || s3 == s0 || s3 == s1 {
+						continue
+					}
+					idx := tb.encode(d, []Square{s0, s1, s2, s3}, 0)
+					if idx < 0 || idx >= len(seen) {
+						t.Fatalf("%v %v %v %v: got index %v, want [0, %v)", s0, s1, s2, s3, idx, len(seen))
+					}
+					seen[idx] = true
+					if got := tb.encode(d, []Square{s0, s1, s3, s2}, 0); got != idx {
+						t.Fatalf("%v %v %v %v: got index %v for the rooks exchanged, want %v", s0, s1, s2, s3, got, idx)
+					}
+				}
+			}
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Fatalf("index %v not encoded", i)
+		}
+	}
+}
+
+func TestSyzygyEncodePawn(t *testing.T) {
+	tb, _ := newSyzygyTable("", "KPvK", false)
+	for f := 0; f < 4; f++ {
+		d := &tb.pairs[0][f]
+		d.pieces = [syzygyMaxPieces]int{1, 6, 14}
+		d.setGroups(tb, [2]int{0, 0xf}, f)
+		if want := 6 * 63 * 62; d.size() != want {
+			t.Fatalf("file %v: got size %v, want %v", f, d.size(), want)
+		}
+	}
+	var seen [4][]bool
+	for f := range seen {
+		seen[f] = make([]bool, tb.pairs[0][f].size())
+	}
+	for p := a2; p <= h7; p++ {
+		f := int(p.File())
+		if f > 3 {
+			f = 7 - f
+		}
+		d := &tb.pairs[0][f]
+		for s1 := a1; s1 <= h8; s1++ {
+			for s2 := a1; s2 <= h8; s2++ {
+				if s1 == p || s2 == p || s1 == s2 {
+					continue
+				}
+				idx := tb.encode(d, []Square{p, s1, s2}, 1)
+				if idx < 0 || idx >= len(seen[f]) {
+					t.Fatalf("%v %v %v: got index %v, want [0, %v)", p, s1, s2, idx, len(seen[f]))
+				}
+				seen[f][idx] = true
+				if got := tb.encode(d, []Square{p ^ 7, s1 ^ 7, s2 ^ 7}, 1); got != idx {
+					t.Fatalf("%v %v %v: got index %v for the mirror image, want %v", p, s1, s2, got, idx)
+				}
+			}
+		}
+	}
+	for f := range seen {
+		for i, ok := range seen[f] {
+			if !ok {
+				t.Fatalf("file %v: index %v not encoded", f, i)
+			}
+		}
+	}
+}
+
+// writeSyzygyTable writes a KRvK table whose every position with each side to move holds the same value.
+// values holds the value with White to move followed by, in a WDL table, the value with Black to move.
+func writeSyzygyTable(t *testing.T, dir string, dtz bool, values ...byte) {
+	name, magic := "KRvK"+syzygyWDLExt, uint32(syzygyWDLMagic)
+	if dtz {
+		name, magic = "KRvK"+syzygyDTZExt, syzygyDTZMagic
+	}
+	data := binary.LittleEndian.AppendUint32(nil, magic)
+	// split, the order of the groups, and the pieces White king, White rook, and Black king for each side
+	data = append(data, 1, 0x00, 0x66, 0x44, 0xee)
+	data = append(data, 0)
+	for _, v := range values {
+		data = append(data, syzygySingleValue, v)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyzygy(t *testing.T) {
+	if _, err := OpenSyzygy(t.TempDir()); err == nil {
+		t.Error("empty directory: got nil error")
+	}
+
+	dir := t.TempDir()
+	writeSyzygyTable(t, dir, false, byte(wdlWin+2), byte(wdlLoss+2))
+	writeSyzygyTable(t, dir, true, 7)
+	if err := os.WriteFile(filepath.Join(dir, "KQvK"+syzygyWDLExt), []byte("not a table"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := OpenSyzygy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.maxPieces() != 3 {
+		t.Errorf("got maxPieces %v, want 3", tb.maxPieces())
+	}
+
+	for _, test := range []struct {
+		fen string
+		ok  bool
+		wdl wdl
+		dtz int
+	}{
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", true, wdlWin, 15},
+		// The DTZ table holds positions with White to move, so Black's best move is searched.
+		{"4k3/8/8/8/8/8/8/R3K3 b - - 0 1", true, wdlLoss, -16},
+		// The colors are reversed.
+		{"r3k3/8/8/8/8/8/8/4K3 b - - 0 1", true, wdlWin, 15},
+		// The king captures the rook.
+		{"8/8/8/8/8/8/kR6/4K3 b - - 0 1", true, wdlDraw, 0},
+		{"8/8/8/8/8/8/k7/4K3 b - - 0 1", true, wdlDraw, 0},
+		// invalid table
+		{"4k3/8/8/8/8/8/8/Q3K3 w - - 0 1", false, 0, 0},
+		// missing table
+		{"4k3/8/8/8/8/8/8/B3K3 w - - 0 1", false, 0, 0},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, ok := tb.probeWDL(pos); ok != test.ok || w != test.wdl {
+			t.Errorf("%v: got WDL %v, %v, want %v, %v", test.fen, w, ok, test.wdl, test.ok)
+		}
+		if dtz, ok := tb.probeDTZ(pos); ok != test.ok || dtz != test.dtz {
+			t.Errorf("%v: got DTZ %v, %v, want %v, %v", test.fen, dtz, ok, test.dtz, test.ok)
+		}
+	}
+}
+
+// syzygyTestEncoding is an encoding of the values of a table for writeSyzygyFile.
+type syzygyTestEncoding struct {
+	flags  byte
+	values []int
+
+	// maps holds the maps of DTZ values of an encoding with syzygyMapped set: win, loss, cursed win, and blessed loss.
+	maps [4][]int
+}
+
+// compressSyzygy compresses values as Syzygy tables do, replacing frequent pairs of adjacent symbols by new symbols
+// and coding the symbols by canonical Huffman codes into blocks of 1<<blockBits bytes, with a sparse index entry
+// for every 1<<spanBits values. It returns the header of the encoding, its sparse index, its block lengths, and its blocks.
+func compressSyzygy(flags byte, values []int, blockBits, spanBits int) (header, sparse, lengths, blocks []byte) {
+	// A symbol stands for the value left if right is negative, and otherwise for the pair of symbols left and right.
+	// n is the number of values for which it stands.
+	type symbol struct{ left, right, n int }
+	var syms []symbol
+	for _, v := range values {
+		for len(syms) <= v {
+			syms = append(syms, symbol{len(syms), -1, 1})
+		}
+	}
+	seq := append([]int(nil), values...)
+	for len(syms) < 64 {
+		counts := make(map[[2]int]int)
+		var best [2]int
+		for i := 1; i < len(seq); i++ {
+			p := [2]int{seq[i-1], seq[i]}
+			if counts[p]++; counts[p] > counts[best] {
+				best = p
+			}
+		}
+		if counts[best] < 4 {
+			break
+		}
+		s := len(syms)
+		syms = append(syms, symbol{best[0], best[1], syms[best[0]].n + syms[best[1]].n})
+		var paired []int
+		for i := 0; i < len(seq); i++ {
+			if i+1 < len(seq) && seq[i] == best[0] && seq[i+1] == best[1] {
+				paired = append(paired, s)
+				i++
+			} else {
+				paired = append(paired, seq[i])
+			}
+		}
+		seq = paired
+	}
+
+	// Find the length of the Huffman code of each symbol by repeatedly joining the two least frequent subtrees.
+	// Symbols that no longer occur have no code.
+	freq := make([]int, len(syms))
+	for _, s := range seq {
+		freq[s]++
+	}
+	type subtree struct {
+		freq int
+		syms []int
+	}
+	var trees []subtree
+	for s, f := range freq {
+		if f > 0 {
+			trees = append(trees, subtree{f, []int{s}})
+		}
+	}
+	codeLen := make([]int, len(syms))
+	if len(trees) == 1 {
+		codeLen[trees[0].syms[0]] = 1
+	}
+	for len(trees) > 1 {
+		sort.SliceStable(trees, func(i, j int) bool { return trees[i].freq < trees[j].freq })
+		joined := subtree{trees[0].freq + trees[1].freq, append(append([]int(nil), trees[0].syms...), trees[1].syms...)}
+		for _, s := range joined.syms {
+			codeLen[s]++
+		}
+		trees = append(trees[2:], joined)
+	}
+
+	// Number the symbols without codes first, and then those with codes from the longest to the shortest.
+	order := make([]int, len(syms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := codeLen[order[i]], codeLen[order[j]]
+		return b != 0 && (a == 0 || a > b)
+	})
+	id := make([]int, len(syms))
+	count := make([]int, 64)
+	minLen, maxLen, uncoded := 64, 0, 0
+	for i, s := range order {
+		id[s] = i
+		l := codeLen[s]
+		if l == 0 {
+			uncoded++
+			continue
+		}
+		count[l]++
+		if l < minLen {
+			minLen = l
+		}
+		if l > maxLen {
+			maxLen = l
+		}
+	}
+	n := maxLen - minLen + 1
+	lowest, base := make([]int, n), make([]int, n)
+	lowest[n-1] = uncoded
+	for i := n - 2; i >= 0; i-- {
+		lowest[i] = lowest[i+1] + count[minLen+i+1]
+		base[i] = (base[i+1] + count[minLen+i+1]) / 2
+	}
+
+	// Code the symbols into blocks, starting a new block when the next code or its values do not fit.
+	blockSize := 1 << blockBits
+	var starts []int // the index of the first value of each block
+	var block []byte
+	var acc uint64
+	var bits, blockBitsUsed, blockValues, idx int
+	endBlock := func() {
+		for ; bits > 0; bits -= 8 {
+			block = append(block, byte(acc>>56))
+			acc <<= 8
+		}
+		blocks = append(blocks, append(block, make([]byte, blockSize-len(block))...)...)
+		lengths = binary.LittleEndian.AppendUint16(lengths, uint16(blockValues-1))
+		block, acc, bits, blockBitsUsed, blockValues = nil, 0, 0, 0, 0
+	}
+	for _, s := range seq {
+		l := codeLen[s]
+		if blockValues > 0 && (blockBitsUsed+l > 8*blockSize || blockValues+syms[s].n > 1<<16) {
+			endBlock()
+		}
+		if blockValues == 0 {
+			starts = append(starts, idx)
+		}
+		code := base[l-minLen] + id[s] - lowest[l-minLen]
+		acc |= uint64(code) << (64 - bits - l)
+		for bits += l; bits >= 8; bits -= 8 {
+			block = append(block, byte(acc>>56))
+			acc <<= 8
+		}
+		blockBitsUsed += l
+		blockValues += syms[s].n
+		idx += syms[s].n
+	}
+	endBlock()
+
+	// Each entry of the sparse index gives the block and offset within it of the middle index of its span.
+	span := 1 << spanBits
+	for k := 0; k < (len(values)+span-1)/span; k++ {
+		mid := k*span + span/2
+		b := sort.Search(len(starts), func(i int) bool { return starts[i] > mid }) - 1
+		sparse = binary.LittleEndian.AppendUint32(sparse, uint32(b))
+		sparse = binary.LittleEndian.AppendUint16(sparse, uint16(mid-starts[b]))
+	}
+
+	header = []byte{flags, byte(blockBits), byte(spanBits), 0}
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(starts)))
+	header = append(header, byte(maxLen), byte(minLen))
+	for _, l := range lowest {
+		header = binary.LittleEndian.AppendUint16(header, uint16(l))
+	}
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(syms)))
+	btree := make([]byte, 3*len(syms)+len(syms)&1)
+	for s, sym := range syms {
+		left, right := sym.left, 0xfff
+		if sym.right >= 0 {
+			left, right = id[sym.left], id[sym.right]
+		}
+		lr := btree[3*id[s]:]
+		lr[0], lr[1], lr[2] = byte(left), byte(left>>8|right<<4), byte(right>>4)
+	}
+	return append(header, btree...), sparse, lengths, blocks
+}
+
+// writeSyzygyFile writes a table without pawns to the named file. pieces holds its pieces in the order in which
+// they are encoded, as 1 (pawn) to 6 (king) plus 8 for Black, and encodings its encoding for each side to move
+// in a WDL table, or the one of a DTZ table.
+func writeSyzygyFile(t *testing.T, name string, dtz bool, pieces []int, encodings ...syzygyTestEncoding) {
+	magic := uint32(syzygyWDLMagic)
+	if dtz {
+		magic = syzygyDTZMagic
+	}
+	data := binary.LittleEndian.AppendUint32(nil, magic)
+	// no pawns, and the order of the groups
+	data = append(data, 1, 0x00)
+	for _, p := range pieces {
+		data = append(data, byte(p|p<<4))
+	}
+	data = append(data, make([]byte, len(data)&1)...)
+	var sparse, lengths, blocks [][]byte
+	for _, e := range encodings {
+		h, s, l, b := compressSyzygy(e.flags, e.values, 5, 6)
+		data = append(data, h...)
+		sparse, lengths, blocks = append(sparse, s), append(lengths, l), append(blocks, b)
+	}
+	for _, e := range encodings {
+		if !dtz || e.flags&syzygyMapped == 0 {
+			continue
+		}
+		for _, m := range e.maps {
+			data = append(data, byte(len(m)))
+			for _, v := range m {
+				data = append(data, byte(v))
+			}
+		}
+		data = append(data, make([]byte, len(data)&1)...)
+	}
+	for _, s := range sparse {
+		data = append(data, s...)
+	}
+	for _, l := range lengths {
+		data = append(data, l...)
+	}
+	for _, b := range blocks {
+		data = append(data, make([]byte, -len(data)&0x3f)...)
+		data = append(data, b...)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyzygyCompressed(t *testing.T) {
+	tb, _ := newSyzygyTable("", "KRvK", false)
+	d := &tb.pairs[0][0]
+	d.pieces = [syzygyMaxPieces]int{6, 4, 14}
+	d.setGroups(tb, [2]int{0, 0xf}, 0)
+	// Runs of values, which pairing shortens, and lower values more often than higher ones, which are given longer codes.
+	r := rand.New(rand.NewSource(1))
+	values := func(max int) []int {
+		v := make([]int, d.size())
+		for i := range v {
+			v[i] = r.Intn(max+1) * r.Intn(max+1) / max
+			if i > 0 && r.Intn(8) != 0 {
+				v[i] = v[i-1]
+			}
+		}
+		return v
+	}
+	wdlValues := [2][]int{values(4), values(4)}
+	dtzValues := values(60)
+	dtzEncoding := syzygyTestEncoding{flags: syzygyMapped | syzygyWinPlies | syzygyLossPlies, values: dtzValues}
+	for v := 0; v <= 60; v++ {
+		dtzEncoding.maps[0] = append(dtzEncoding.maps[0], 3*v+1)
+	}
+
+	dir := t.TempDir()
+	pieces := []int{6, 4, 14}
+	writeSyzygyFile(t, filepath.Join(dir, "KRvK"+syzygyWDLExt), false, pieces,
+		syzygyTestEncoding{values: wdlValues[White]}, syzygyTestEncoding{values: wdlValues[Black]})
+	writeSyzygyFile(t, filepath.Join(dir, "KRvK"+syzygyDTZExt), true, pieces, dtzEncoding)
+	syz, err := OpenSyzygy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, z := syz.wdl["KRvK"], syz.dtz["KRvK"]
+	for _, st := range []*syzygyTable{w, z} {
+		if err := st.load(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for stm, values := range wdlValues {
+		pd := &w.pairs[stm][0]
+		if pd.numBlocks < 2 || len(pd.symLen) <= 5 || len(pd.base64) < 2 {
+			t.Errorf("WDL %v: got %v blocks, %v symbols, and %v code lengths, want more", Color(stm), pd.numBlocks, len(pd.symLen), len(pd.base64))
+		}
+		for i, want := range values {
+			if got := pd.decompress(i); got != want {
+				t.Fatalf("WDL %v: got value %v at index %v, want %v", Color(stm), got, i, want)
+			}
+		}
+	}
+	pd := &z.pairs[0][0]
+	for i, v := range dtzValues {
+		if got, want := z.mapDTZ(pd, 0, pd.decompress(i), wdlWin), dtzEncoding.maps[0][v]+1; got != want {
+			t.Fatalf("DTZ: got %v at index %v, want %v", got, i, want)
+		}
+	}
+
+	// Positions in which no piece can be captured are probed as the tables give them.
+	for _, fen := range []string{
+		"4k3/8/8/8/8/8/8/R3K3 w - - 0 1",
+		"4k3/8/8/8/8/8/8/R3K3 b - - 0 1",
+		"8/2k5/8/8/5R2/8/1K6/8 w - - 0 1",
+		"8/2k5/8/8/5R2/8/1K6/8 b - - 0 1",
+		"r3k3/8/8/8/8/8/8/4K3 b - - 0 1",
+		"7K/8/8/3R4/8/8/8/k7 w - - 0 1",
+	} {
+		pos, err := ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := White
+		if pos.b[Black][Rook] != 0 {
+			// The colors are reversed.
+			c = Black
+		}
+		var flip Square
+		if c == Black {
+			flip = 56
+		}
+		stm := pos.ToMove ^ c
+		idx := tb.encode(d, []Square{pos.KingSquare[c] ^ flip, LS1BIndex(pos.b[c][Rook]) ^ flip, pos.KingSquare[c^1] ^ flip}, 0)
+		want := wdl(wdlValues[stm][idx] - 2)
+		if got, ok := syz.probeWDL(pos); !ok || got != want {
+			t.Errorf("%v: got WDL %v, %v, want %v", fen, got, ok, want)
+		}
+		if stm == White && want == wdlWin {
+			if got, ok := syz.probeDTZ(pos); !ok || got != dtzEncoding.maps[0][dtzValues[idx]]+1 {
+				t.Errorf("%v: got DTZ %v, %v, want %v", fen, got, ok, dtzEncoding.maps[0][dtzValues[idx]]+1)
+			}
+		}
+	}
+}
+
+// TestSyzygyFiles probes the published KQvK, KRvK, and KPvK tables in testdata/syzygy for positions whose outcomes are known.
+func TestSyzygyFiles(t *testing.T) {
+	tb, err := OpenSyzygy(filepath.Join("testdata", "syzygy"))
+	if err != nil {
+		t.Skipf("published tables not found: %v", err)
+	}
+	for _, test := range []struct {
+		fen  string
+		wdl  wdl
+		dtz  int
+		keep string // the moves kept by the root filter
+	}{
+		// checkmate in one
+		{"k7/8/1K6/8/8/8/8/7Q w - - 0 1", wdlWin, 1, "h1b7 h1h8"},
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", wdlWin, 1, "h1h8"},
+		// The king captures the rook.
+		{"8/8/8/8/8/8/kR6/4K3 b - - 0 1", wdlDraw, 0, "a2b2"},
+		// Promotion to a queen or a rook wins at once.
+		{"k7/4P3/8/8/8/8/8/4K3 w - - 0 1", wdlWin, 1, "e7e8q e7e8r"},
+		// The defending king reaches the corner in front of the rook pawn.
+		{"7k/8/8/8/8/8/7P/7K w - - 0 1", wdlDraw, 0, "h1g1 h1g2 h2h3 h2h4"},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w, ok := tb.probeWDL(pos); !ok || w != test.wdl {
+			t.Errorf("%v: got WDL %v, %v, want %v", test.fen, w, ok, test.wdl)
+		}
+		if dtz, ok := tb.probeDTZ(pos); !ok || dtz != test.dtz {
+			t.Errorf("%v: got DTZ %v, %v, want %v", test.fen, dtz, ok, test.dtz)
+		}
+		var keep []string
+		for _, r := range tablebaseResults(tb, pos) {
+			keep = append(keep, Coordinate(r.move))
+		}
+		sort.Strings(keep)
+		if got := strings.Join(keep, " "); got != test.keep {
+			t.Errorf("%v: got moves %q, want %q", test.fen, got, test.keep)
+		}
+	}
+}
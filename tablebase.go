@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// A tablebase holds the outcomes under perfect play of positions with few pieces.
+type tablebase interface {
+	// maxPieces returns the greatest number of pieces, including the kings, of any position it may hold.
+	maxPieces() int
+
+	// probeWDL returns the outcome of pos for the side to move, assuming that the fifty-move counter was just reset.
+	// It reports false if pos is not held.
+	probeWDL(pos Position) (wdl, bool)
+
+	// probeDTZ returns the number of plies until the fifty-move counter is reset by a capture or pawn move
+	// that preserves the outcome of pos: positive if the side to move wins, negative if it loses, and 0 if drawn.
+	// The distances of cursed wins and blessed losses exceed 100. It reports false if pos is not held.
+	probeDTZ(pos Position) (int, bool)
+}
+
+// tablebases is the tablebase that searches probe, or nil if none is used.
+var tablebases tablebase
+
+// wdl is the outcome of a position for the side to move: a win, draw, or loss,
+// or a cursed win or blessed loss that the fifty-move rule turns into a draw.
+type wdl int
+
+const (
+	wdlLoss wdl = iota - 2
+	wdlBlessedLoss
+	wdlDraw
+	wdlCursedWin
+	wdlWin
+)
+
+// tablebaseWin is the score of a position that a tablebase shows to be won, which exceeds any evaluation
+// but not a checkmate, since the tablebase does not give the distance to it.
+const tablebaseWin = 20000
+
+// errTablebaseDraw is the score of a position that a tablebase shows to be drawn.
+var errTablebaseDraw = errors.New("tablebase draw")
+
+// wdlScore returns the score of a position with the outcome w.
+func wdlScore(w wdl) Rel {
+	switch w {
+	case wdlWin:
+		return Rel{n: tablebaseWin}
+	case wdlLoss:
+		return Rel{n: -tablebaseWin}
+	}
+	return Rel{err: errTablebaseDraw}
+}
+
+// dtzBeforeZeroing returns the DTZ of a position in which a capture or pawn move reaches a position with outcome w
+// for the side that made it.
+func dtzBeforeZeroing(w wdl) int {
+	switch w {
+	case wdlWin:
+		return 1
+	case wdlCursedWin:
+		return 101
+	case wdlBlessedLoss:
+		return -101
+	case wdlLoss:
+		return -1
+	}
+	return 0
+}
+
+// tablebaseHolds reports whether pos has few enough pieces to be held by tb, and no castling rights, which tablebases omit.
+func tablebaseHolds(tb tablebase, pos Position) bool {
+	return tb != nil && pos.Castle == [2][2]bool{} && PopCount(pos.b[White][All]|pos.b[Black][All]) <= tb.maxPieces()
+}
+
+// tablebaseResults returns Results of the moves of pos that best preserve its outcome under perfect play according to tb,
+// or nil if tb does not hold pos. A win is best pursued by the move that resets the fifty-move counter soonest,
+// and a loss by the one that resets it latest.
+func tablebaseResults(tb tablebase, pos Position) Results {
+	if !tablebaseHolds(tb, pos) {
+		return nil
+	}
+	var rs Results
+	best := math.MinInt
+	for _, m := range LegalMoves(pos) {
+		child := Make(pos, m)
+		var dtz int
+		if child.HalfMove == 0 {
+			w, ok := tb.probeWDL(child)
+			if !ok {
+				return nil
+			}
+			dtz = dtzBeforeZeroing(-w)
+		} else {
+			d, ok := tb.probeDTZ(child)
+			if !ok {
+				return nil
+			}
+			switch dtz = -d; {
+			case dtz > 0:
+				dtz++
+			case dtz < 0:
+				dtz--
+			}
+		}
+		if dtz == 2 && IsCheck(child) && IsMate(child) {
+			// The move mates, without resetting the counter.
+			dtz = 1
+		}
+		switch r := dtzRank(dtz, pos.HalfMove); {
+		case r > best:
+			best, rs = r, Results{{move: m}}
+		case r == best:
+			rs = append(rs, Result{move: m})
+		}
+	}
+	return rs
+}
+
+// dtzRank ranks a move by dtz, the DTZ of the position before it for the side that plays it, where halfMove is the
+// fifty-move counter before the move. Wins rank above cursed wins, which the opponent may yet lose, and those above draws.
+func dtzRank(dtz, halfMove int) int {
+	switch {
+	case dtz > 0 && dtz+halfMove <= 100:
+		return 20000 - dtz
+	case dtz > 0:
+		return 10000 - dtz
+	case dtz < 0 && halfMove-dtz <= 100:
+		return -20000 - dtz
+	case dtz < 0:
+		return -10000 - dtz
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// materialTablebase is a tablebase of the positions with at most its number of pieces, in which a side wins
+// if only it has a rook or queen, and the DTZ is the distance between the kings.
+type materialTablebase int
+
+func (tb materialTablebase) maxPieces() int { return int(tb) }
+
+func (tb materialTablebase) probeWDL(pos Position) (wdl, bool) {
+	heavy := func(c Color) bool { return pos.b[c][Rook]|pos.b[c][Queen] != 0 }
+	switch us, them := heavy(pos.ToMove), heavy(pos.Opp()); {
+	case us && !them:
+		return wdlWin, true
+	case them && !us:
+		return wdlLoss, true
+	}
+	return wdlDraw, true
+}
+
+func (tb materialTablebase) probeDTZ(pos Position) (int, bool) {
+	w, _ := tb.probeWDL(pos)
+	k0, k1 := pos.KingSquare[White], pos.KingSquare[Black]
+	df, dr := int(k0.File())-int(k1.File()), int(k0.Rank())-int(k1.Rank())
+	if df < 0 {
+		df = -df
+	}
+	if dr < 0 {
+		dr = -dr
+	}
+	if dr > df {
+		df = dr
+	}
+	return sign(int(w)) * df, true
+}
+
+func TestTablebaseResults(t *testing.T) {
+	for _, test := range []struct {
+		fen  string
+		tb   tablebase
+		want string // the moves, sorted, or "" for nil Results
+	}{
+		// The moves that approach the other king most closely.
+		{"8/8/8/4k3/8/8/8/R3K3 w - - 10 20", materialTablebase(3), "e1d2 e1e2 e1f2"},
+		{"8/8/8/4k3/8/8/8/R3K3 w - - 10 20", nil, ""},
+		{"8/8/8/4k3/8/8/8/R3K3 w - - 10 20", materialTablebase(2), ""},
+		{"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", materialTablebase(3), ""},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs := tablebaseResults(test.tb, pos)
+		var got []string
+		for _, r := range rs {
+			got = append(got, Coordinate(r.move))
+		}
+		sort.Strings(got)
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%v, %v: got %v, want %v", test.fen, test.tb, got, test.want)
+		}
+	}
+}
+
+func TestDTZRank(t *testing.T) {
+	// in descending order of rank
+	moves := []struct{ dtz, halfMove int }{
+		{1, 0},
+		{3, 90},
+		{5, 0},
+		{5, 96},  // cursed win
+		{0, 0},   // draw
+		{-3, 98}, // blessed loss
+		{-9, 0},
+		{-3, 0},
+	}
+	for i := 1; i < len(moves); i++ {
+		a, b := moves[i-1], moves[i]
+		if ra, rb := dtzRank(a.dtz, a.halfMove), dtzRank(b.dtz, b.halfMove); ra <= rb {
+			t.Errorf("dtzRank(%v, %v) = %v, want greater than dtzRank(%v, %v) = %v", a.dtz, a.halfMove, ra, b.dtz, b.halfMove, rb)
+		}
+	}
+}
+
+func TestSearchTablebase(t *testing.T) {
+	defer func(tt *TransTable, tb tablebase) { hashTable, tablebases = tt, tb }(hashTable, tablebases)
+	hashTable = NewTransTable(1)
+
+	for _, test := range []struct {
+		fen   string
+		tb    tablebase
+		move  string
+		score Rel
+	}{
+		// Capturing the rook reaches a won position, which the search takes from the tablebase.
+		{"4k3/8/8/8/8/8/r7/R3K3 w - - 0 1", materialTablebase(3), "a1a2", Rel{n: tablebaseWin}},
+		// The only move, capturing the rook, reaches a drawn position.
+		{"k7/1R5p/7P/8/8/3K4/8/8 b - - 0 1", materialTablebase(4), "a8b7", Rel{err: errTablebaseDraw}},
+	} {
+		hashTable.Clear()
+		tablebases = test.tb
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var info Info
+		rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: 3, OnIteration: func(i Info) { info = i }})
+		if got, score := Coordinate(rs[0].move), rs[0].score.Rel(pos.ToMove); got != test.move || score != test.score {
+			t.Errorf("%v: got %v %v, want %v %v", test.fen, got, score, test.move, test.score)
+		}
+		if info.TBHits == 0 {
+			t.Errorf("%v: got 0 tablebase hits", test.fen)
+		}
+	}
+}
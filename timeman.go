@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMovesToGo is the assumed number of moves remaining until the next time control
+	// when it is not otherwise known.
+	defaultMovesToGo = 30
+
+	// clockOverhead is reserved from the remaining clock time to allow for communication latency.
+	clockOverhead = 50 * time.Millisecond
+
+	// hardTimeFactor is the greatest multiple of the soft time limit that the hard limit may reach.
+	hardTimeFactor = 5
+
+	// scoreDropMargin is the fall in the root score, in centipawns, from one iteration to the next
+	// that causes the search to be extended.
+	scoreDropMargin = 30
+)
+
+// A timeControl describes a game clock.
+type timeControl struct {
+	// moves is the number of moves per time control, or 0 for a single control for the whole game.
+	moves int
+
+	// base is the time allotted per control, and inc is the increment per move.
+	base, inc time.Duration
+}
+
+// parseTimeControl parses s as a time control of the form [moves/]minutes[+increment],
+// where the increment is in seconds (e.g. 5+3, 40/90+30, 0.5).
+func parseTimeControl(s string) (timeControl, error) {
+	var tc timeControl
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil || n <= 0 {
+			return tc, fmt.Errorf("invalid time control %v: invalid number of moves", s)
+		}
+		tc.moves, s = n, s[i+1:]
+	}
+	base, inc := s, "0"
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		base, inc = s[:i], s[i+1:]
+	}
+	min, err := strconv.ParseFloat(base, 64)
+	if err != nil || min <= 0 {
+		return tc, fmt.Errorf("invalid time control %v: invalid base time", s)
+	}
+	sec, err := strconv.ParseFloat(inc, 64)
+	if err != nil || sec < 0 {
+		return tc, fmt.Errorf("invalid time control %v: invalid increment", s)
+	}
+	tc.base = time.Duration(min * float64(time.Minute))
+	tc.inc = time.Duration(sec * float64(time.Second))
+	return tc, nil
+}
+
+// String returns a string representation of tc in the form accepted by parseTimeControl.
+func (tc timeControl) String() string {
+	s := strconv.FormatFloat(tc.base.Minutes(), 'f', -1, 64)
+	if tc.moves > 0 {
+		s = fmt.Sprintf("%v/%v", tc.moves, s)
+	}
+	if tc.inc > 0 {
+		s += "+" + strconv.FormatFloat(tc.inc.Seconds(), 'f', -1, 64)
+	}
+	return s
+}
+
+// pgn returns the representation of tc in a PGN TimeControl tag.
+func (tc timeControl) pgn() string {
+	base := strconv.FormatFloat(tc.base.Seconds(), 'f', -1, 64)
+	switch {
+	case tc.moves > 0:
+		return fmt.Sprintf("%v/%v", tc.moves, base)
+	case tc.inc > 0:
+		return base + "+" + strconv.FormatFloat(tc.inc.Seconds(), 'f', -1, 64)
+	default:
+		return base
+	}
+}
+
+// A gameClock tracks a player's remaining time under a timeControl.
+type gameClock struct {
+	tc        timeControl
+	remaining time.Duration
+
+	// moves is the number of moves the player has made.
+	moves int
+}
+
+// newGameClock returns a gameClock set to the beginning of a game under tc.
+func newGameClock(tc timeControl) *gameClock { return &gameClock{tc: tc, remaining: tc.base} }
+
+// punch charges g for a move that took the elapsed time and reports whether any time remained.
+// If so, it adds the increment, and the base time if the move completes a time control.
+func (g *gameClock) punch(elapsed time.Duration) bool {
+	if g.remaining -= elapsed; g.remaining < 0 {
+		return false
+	}
+	g.remaining += g.tc.inc
+	if g.moves++; g.tc.moves > 0 && g.moves%g.tc.moves == 0 {
+		g.remaining += g.tc.base
+	}
+	return true
+}
+
+// movesToGo returns the number of moves until the next time control, or 0 if there is none.
+func (g *gameClock) movesToGo() int {
+	if g.tc.moves == 0 {
+		return 0
+	}
+	return g.tc.moves - g.moves%g.tc.moves
+}
+
+// timeManager returns a timeManager for the player's next move.
+func (g *gameClock) timeManager() timeManager {
+	return newTimeManager(g.remaining, g.tc.inc, g.movesToGo())
+}
+
+// A timeManager limits the duration of an iterative deepening search.
+// The zero value imposes no limit.
+type timeManager struct {
+	// soft is the target duration of the search. Since each iteration usually takes longer than
+	// all of the preceding ones, no further iteration is begun once half of it has elapsed.
+	// It is shortened while the best move is stable and lengthened when the score drops.
+	// A soft limit of 0 lets the search run until the hard limit.
+	soft time.Duration
+
+	// hard is the duration after which the search is stopped, or 0 for no limit.
+	hard time.Duration
+
+	start time.Time
+
+	// best and score are the best move and its score relative to the side to move
+	// as of the most recent iteration, and iterations is the number of iterations completed.
+	best       Move
+	score      Rel
+	iterations int
+
+	// stable is the number of consecutive iterations that have not changed the best move.
+	stable int
+
+	// dropped reports whether the score fell by at least scoreDropMargin in the most recent iteration.
+	dropped bool
+}
+
+// newTimeManager returns a timeManager for a move to be played with the given remaining clock time,
+// increment per move, and number of moves until the next time control.
+func newTimeManager(remaining, inc time.Duration, movesToGo int) timeManager {
+	soft := allotTime(remaining, inc, movesToGo)
+	if soft == 0 {
+		// No time remains; move as fast as possible.
+		return fixedTime(time.Millisecond)
+	}
+	hard := (remaining - clockOverhead) / 4
+	if hard < soft {
+		hard = soft
+	}
+	if max := hardTimeFactor * soft; hard > max {
+		hard = max
+	}
+	return timeManager{soft: soft, hard: hard}
+}
+
+// fixedTime returns a timeManager that searches for the duration d.
+func fixedTime(d time.Duration) timeManager { return timeManager{hard: d} }
+
+// allotTime returns the time to spend on a move given the remaining clock time,
+// the increment per move, and the number of moves until the next time control.
+// It returns 0 if no clock time remains.
+func allotTime(remaining, inc time.Duration, movesToGo int) time.Duration {
+	if remaining <= 0 {
+		return 0
+	}
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	t := remaining/time.Duration(movesToGo) + inc/2
+	if max := remaining - clockOverhead; t > max {
+		t = max
+	}
+	if t <= 0 {
+		t = time.Millisecond
+	}
+	return t
+}
+
+// iterationDone records the best move and score of a completed iteration and reports whether
+// the search should stop rather than begin another iteration.
+func (tm *timeManager) iterationDone(best Move, score Rel) bool {
+	if tm.iterations > 0 && best == tm.best {
+		tm.stable++
+	} else {
+		tm.best, tm.stable = best, 0
+	}
+	tm.dropped = tm.iterations > 0 && tm.score.err == nil && Less(Score(score), Score(Rel{n: tm.score.n - scoreDropMargin}))
+	tm.score = score
+	tm.iterations++
+	return tm.soft > 0 && time.Since(tm.start) >= tm.softLimit()/2
+}
+
+// softLimit returns the soft limit adjusted for the stability of the best move
+// and for whether the score has dropped in the most recent iteration.
+func (tm *timeManager) softLimit() time.Duration {
+	// The more iterations the best move has survived, the less likely it is to change.
+	stable := tm.stable
+	if stable > 5 {
+		stable = 5
+	}
+	limit := tm.soft * time.Duration(10-stable) / 10
+	if tm.dropped {
+		// Look for a way out of trouble.
+		limit *= 2
+	}
+	return limit
+}
+
+// A searchClock applies a timeManager to a running search, which may have begun before the timeManager
+// was started, as when pondering on the opponent's time. It is safe for concurrent use.
+type searchClock struct {
+	mu      sync.Mutex
+	tm      timeManager
+	started bool
+	timer   *time.Timer
+
+	// cancel stops the search.
+	cancel context.CancelFunc
+}
+
+// newSearchClock returns a searchClock that stops a search by calling cancel.
+func newSearchClock(cancel context.CancelFunc) *searchClock { return &searchClock{cancel: cancel} }
+
+// start starts the timer of tm, after whose hard limit the search is stopped.
+// It has no effect if c has already been started.
+func (c *searchClock) start(tm timeManager) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return
+	}
+	tm.start = time.Now()
+	c.tm, c.started = tm, true
+	if tm.hard > 0 {
+		c.timer = time.AfterFunc(tm.hard, c.cancel)
+	}
+}
+
+// running reports whether c has been started.
+func (c *searchClock) running() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started
+}
+
+// iterationDone records the best move and score of a completed iteration and reports whether
+// the search should stop rather than begin another iteration. Until c is started, the search continues.
+func (c *searchClock) iterationDone(best Move, score Rel) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started && c.tm.iterationDone(best, score)
+}
+
+// stop stops the timer of c.
+func (c *searchClock) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
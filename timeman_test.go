@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want timeControl
+		pgn  string
+	}{
+		{"5+3", timeControl{base: 5 * time.Minute, inc: 3 * time.Second}, "300+3"},
+		{"40/90+30", timeControl{moves: 40, base: 90 * time.Minute, inc: 30 * time.Second}, "40/5400"},
+		{"0.5", timeControl{base: 30 * time.Second}, "30"},
+		{"1+0.5", timeControl{base: time.Minute, inc: 500 * time.Millisecond}, "60+0.5"},
+	} {
+		got, err := parseTimeControl(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("parseTimeControl(%v): got %+v, %v; want %+v", test.s, got, err, test.want)
+			continue
+		}
+		if s := got.String(); s != test.s {
+			t.Errorf("String(%+v): got %v, want %v", got, s, test.s)
+		}
+		if pgn := got.pgn(); pgn != test.pgn {
+			t.Errorf("pgn(%+v): got %v, want %v", got, pgn, test.pgn)
+		}
+	}
+	for _, s := range []string{"", "5+", "+3", "0+3", "-1", "5+-1", "x/5", "0/5", "5/", "a+b"} {
+		if tc, err := parseTimeControl(s); err == nil {
+			t.Errorf("parseTimeControl(%v): got %+v, nil; want error", s, tc)
+		}
+	}
+}
+
+func TestGameClock(t *testing.T) {
+	g := newGameClock(timeControl{moves: 2, base: time.Minute, inc: time.Second})
+	for _, test := range []struct {
+		elapsed   time.Duration
+		ok        bool
+		remaining time.Duration
+		movesToGo int
+	}{
+		{10 * time.Second, true, 51 * time.Second, 1},
+		{20 * time.Second, true, 92 * time.Second, 2},
+		{92 * time.Second, true, time.Second, 1},
+		{2 * time.Second, false, -time.Second, 1},
+	} {
+		if ok := g.punch(test.elapsed); ok != test.ok || g.remaining != test.remaining || g.movesToGo() != test.movesToGo {
+			t.Errorf("punch(%v): got %v, %v, %v; want %v, %v, %v", test.elapsed, ok, g.remaining, g.movesToGo(), test.ok, test.remaining, test.movesToGo)
+		}
+	}
+}
+
+func TestTimeManager(t *testing.T) {
+	a, b := Move{From: e2, To: e4, Piece: Pawn}, Move{From: d2, To: d4, Piece: Pawn}
+	tm := newTimeManager(time.Minute, 0, 0)
+	tm.start = time.Now()
+	for _, test := range []struct {
+		best  Move
+		score Rel
+		want  time.Duration
+	}{
+		{a, Rel{n: 20}, 2 * time.Second},
+		{a, Rel{n: 25}, 1800 * time.Millisecond},
+		{a, Rel{n: 15}, 1600 * time.Millisecond},
+		{b, Rel{n: -20}, 4 * time.Second},
+		{b, Rel{n: -10}, 1800 * time.Millisecond},
+		{b, Rel{err: errCheckmate.Prev().Prev()}, 3200 * time.Millisecond},
+		{b, Rel{err: errCheckmate.Prev().Prev()}, 1400 * time.Millisecond},
+		{b, Rel{n: 0}, 1200 * time.Millisecond},
+		{b, Rel{n: 0}, time.Second},
+		{b, Rel{n: 0}, time.Second},
+	} {
+		if tm.iterationDone(test.best, test.score) {
+			t.Fatalf("iterationDone(%v, %v): got true, want false", test.best, test.score)
+		}
+		if got := tm.softLimit(); got != test.want {
+			t.Errorf("after iteration (%v, %v): got soft limit %v, want %v", test.best, test.score, got, test.want)
+		}
+	}
+	tm.start = time.Now().Add(-time.Second)
+	if !tm.iterationDone(b, Rel{}) {
+		t.Errorf("iterationDone after soft limit: got false, want true")
+	}
+}
+
+func TestSearchClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newSearchClock(cancel)
+	tm := fixedTime(10 * time.Millisecond)
+	tm.soft = time.Nanosecond
+	if c.running() || c.iterationDone(Move{}, Rel{}) {
+		t.Errorf("before start: got running %v, want false and the search to continue", c.running())
+	}
+	c.start(tm)
+	if !c.running() || !c.iterationDone(Move{}, Rel{}) {
+		t.Errorf("after start: got running %v, want true and the search to stop", c.running())
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("search not canceled after hard limit")
+	}
+}
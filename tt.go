@@ -0,0 +1,237 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+// DefaultHashSize is the default size of the transposition table in megabytes.
+const DefaultHashSize = 64
+
+// hashTable is the transposition table shared by all searches, or nil if none is used.
+var hashTable *TransTable
+
+// bound describes the relationship of a stored score to the true score of a position.
+type bound byte
+
+const (
+	// noBound marks an empty entry.
+	noBound bound = iota
+
+	// exactBound indicates that the stored score is the true score.
+	exactBound
+
+	// lowerBound indicates that the stored score caused a beta cutoff, so the true score is at least as high.
+	lowerBound
+
+	// upperBound indicates that no move raised alpha, so the true score is at most as high as the stored score.
+	upperBound
+)
+
+// ttScore is the compact encoding of a Rel in a transposition table entry.
+//
+// Because checkmateError counts plies from the position in which it is evaluated
+// rather than from the root of the search, a stored checkmate score remains correct
+// when the same position is reached at a different ply, and no further adjustment is required.
+type ttScore struct {
+	n    int32
+	kind int8 // 0: n is in centipawns; 1: n is a checkmateError; otherwise, a drawing error in ttDraws
+}
+
+// ttDraws lists the drawing errors that can be stored in a ttScore, offset by 2.
+var ttDraws = []error{errStalemate, errInsufficient, errFiftyMove, errRepetition, errTablebaseDraw}
+
+// newTTScore returns the ttScore encoding s.
+func newTTScore(s Rel) ttScore {
+	switch err := s.err.(type) {
+	case nil:
+		return ttScore{n: int32(s.n)}
+	case checkmateError:
+		return ttScore{n: int32(err), kind: 1}
+	}
+	for i, err := range ttDraws {
+		if s.err == err {
+			return ttScore{n: int32(s.n), kind: int8(i + 2)}
+		}
+	}
+	panic("unreached")
+}
+
+// Rel returns the Rel encoded by ts.
+func (ts ttScore) Rel() Rel {
+	switch ts.kind {
+	case 0:
+		return Rel{n: int(ts.n)}
+	case 1:
+		return Rel{err: checkmateError(ts.n)}
+	default:
+		return Rel{n: int(ts.n), err: ttDraws[ts.kind-2]}
+	}
+}
+
+// ttEntry holds the result of a search of one position.
+type ttEntry struct {
+	key   Zobrist
+	move  Move // the best Move found, or Move{} if none is known
+	score ttScore
+	depth int8
+	bound bound
+	age   uint8 // the TransTable age when the entry was stored
+}
+
+// ttSlot stores a ttEntry in two words that are read and written atomically, so that a TransTable
+// can be shared by concurrent searches without locking. check is the XOR of the key and data,
+// so an entry whose words were written by different stores fails to match its key and is ignored.
+type ttSlot struct{ check, data uint64 }
+
+// load returns the entry stored in s.
+func (s *ttSlot) load() ttEntry {
+	data := atomic.LoadUint64(&s.data)
+	check := atomic.LoadUint64(&s.check)
+	return unpackEntry(Zobrist(check^data), data)
+}
+
+// save stores e in s.
+func (s *ttSlot) save(e ttEntry) {
+	data := e.pack()
+	atomic.StoreUint64(&s.data, data)
+	atomic.StoreUint64(&s.check, uint64(e.key)^data)
+}
+
+// pack returns the data of e encoded in a single word, from least to most significant bits:
+// the move in 23 bits, the score in 23 bits, then the depth, bound, and age.
+func (e ttEntry) pack() uint64 {
+	m := e.move
+	data := uint64(m.From) | uint64(m.To)<<6 | uint64(m.Piece)<<12 | uint64(m.CapturePiece)<<15 | uint64(m.PromotePiece)<<19
+	if m.EP {
+		data |= 1 << 18
+	}
+	if m.Castle960 {
+		data |= 1 << 22
+	}
+	data |= uint64(uint32(e.score.n)&(1<<20-1))<<23 | uint64(e.score.kind)<<43
+	return data | uint64(uint8(e.depth))<<46 | uint64(e.bound)<<54 | uint64(e.age)<<56
+}
+
+// unpackEntry returns the ttEntry with the given key and packed data.
+func unpackEntry(key Zobrist, data uint64) ttEntry {
+	m := Move{
+		From:         Square(data & 63),
+		To:           Square(data >> 6 & 63),
+		Piece:        Piece(data >> 12 & 7),
+		CapturePiece: Piece(data >> 15 & 7),
+		EP:           data>>18&1 != 0,
+		PromotePiece: Piece(data >> 19 & 7),
+		Castle960:    data>>22&1 != 0,
+	}
+	return ttEntry{
+		key:  key,
+		move: m,
+		// Shift the 20-bit score left and back to extend its sign.
+		score: ttScore{n: int32(data>>23) << 12 >> 12, kind: int8(data >> 43 & 7)},
+		depth: int8(data >> 46),
+		bound: bound(data >> 54 & 3),
+		age:   uint8(data >> 56),
+	}
+}
+
+// ttBucketSize is the number of entries in each bucket of a TransTable.
+// A position may be stored in any entry of the bucket corresponding to its Zobrist key.
+const ttBucketSize = 4
+
+// TransTable is a fixed-size transposition table of the results of position searches, indexed by Zobrist key.
+// It may be probed and stored to by concurrent searches, but not cleared or aged during them.
+type TransTable struct {
+	buckets [][ttBucketSize]ttSlot
+	mask    Zobrist
+
+	// age distinguishes entries stored during the current search from those stored during previous ones.
+	age uint8
+}
+
+// NewTransTable returns a TransTable occupying at most the specified number of megabytes, and at least one bucket.
+func NewTransTable(mb int) *TransTable {
+	bucketSize := int(unsafe.Sizeof([ttBucketSize]ttSlot{}))
+	n := 1
+	for 2*n*bucketSize <= mb<<20 {
+		n *= 2
+	}
+	return &TransTable{buckets: make([][ttBucketSize]ttSlot, n), mask: Zobrist(n - 1)}
+}
+
+// Clear empties tt.
+func (tt *TransTable) Clear() {
+	for i := range tt.buckets {
+		tt.buckets[i] = [ttBucketSize]ttSlot{}
+	}
+	tt.age = 0
+}
+
+// NewSearch ages the entries of tt so that they are preferentially replaced during the next search.
+func (tt *TransTable) NewSearch() { tt.age++ }
+
+// probe returns the entry for the position with Zobrist key z and reports whether it was found.
+func (tt *TransTable) probe(z Zobrist) (ttEntry, bool) {
+	b := &tt.buckets[z&tt.mask]
+	for i := range b {
+		if e := b[i].load(); e.bound != noBound && e.key == z {
+			return e, true
+		}
+	}
+	return ttEntry{}, false
+}
+
+// store records the result of a search of the position with Zobrist key z to the specified depth.
+// An existing entry for the same position is replaced unless it came from a deeper search during the current search.
+// Otherwise, the entry of the bucket with the lowest priority is replaced.
+func (tt *TransTable) store(z Zobrist, move Move, score Rel, b bound, depth int) {
+	bucket := &tt.buckets[z&tt.mask]
+	replace, priority := &bucket[0], math.MaxInt32
+	for i := range bucket {
+		e := bucket[i].load()
+		if e.bound != noBound && e.key == z {
+			if e.age == tt.age && int(e.depth) > depth {
+				return
+			}
+			if move == (Move{}) {
+				// Retain the best move from a previous search of the same position.
+				move = e.move
+			}
+			replace = &bucket[i]
+			break
+		}
+		if p := tt.priority(e); p < priority {
+			replace, priority = &bucket[i], p
+		}
+	}
+	replace.save(ttEntry{key: z, move: move, score: newTTScore(score), depth: int8(depth), bound: b, age: tt.age})
+}
+
+// priority returns the value of retaining e. Entries from deeper searches and from more recent searches
+// have higher priority, and empty entries have the lowest priority.
+func (tt *TransTable) priority(e ttEntry) int {
+	if e.bound == noBound {
+		return math.MinInt32
+	}
+	return int(e.depth) - 4*int(tt.age-e.age)
+}
+
+// Hashfull returns the number of entries used in the current search, in permille.
+// It is estimated by sampling.
+func (tt *TransTable) Hashfull() int {
+	const sample = 1000 / ttBucketSize
+	n := sample
+	if len(tt.buckets) < n {
+		n = len(tt.buckets)
+	}
+	var used int
+	for i := range tt.buckets[:n] {
+		for j := range tt.buckets[i] {
+			if e := tt.buckets[i][j].load(); e.bound != noBound && e.age == tt.age {
+				used++
+			}
+		}
+	}
+	return 1000 * used / (n * ttBucketSize)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTScore(t *testing.T) {
+	for _, s := range []Rel{
+		{n: 0},
+		{n: 35},
+		{n: -120},
+		materel(1),
+		materel(6),
+		{err: errStalemate},
+		{n: 3, err: errInsufficient},
+		{n: -2, err: errFiftyMove},
+		{err: errRepetition},
+		{err: errTablebaseDraw},
+	} {
+		if got := newTTScore(s).Rel(); got != s {
+			t.Errorf("newTTScore(%v).Rel(): got %v", s, got)
+		}
+	}
+}
+
+func TestTTEntryPack(t *testing.T) {
+	for _, e := range []ttEntry{
+		{},
+		{key: 0x1234, move: Move{From: e2, To: e4, Piece: Pawn}, score: newTTScore(Rel{n: -120}), depth: 7, bound: exactBound, age: 255},
+		{key: 0xffffffffffffffff, move: Move{From: d4, To: e3, Piece: Pawn, CapturePiece: Pawn, EP: true}, score: newTTScore(Rel{n: 3, err: errInsufficient}), depth: 1, bound: upperBound},
+		{key: 42, move: Move{From: b7, To: a8, Piece: Pawn, CapturePiece: Rook, PromotePiece: Knight}, score: newTTScore(materel(5)), depth: 127, bound: lowerBound, age: 3},
+		{key: 7, move: Move{From: e1, To: h1, Piece: King, Castle960: true}, score: newTTScore(Rel{n: 1<<19 - 1}), bound: exactBound},
+		{key: 7, move: Move{From: e1, To: h1, Piece: King, Castle960: true}, score: newTTScore(Rel{n: -1 << 19}), bound: exactBound},
+	} {
+		if got := unpackEntry(e.key, e.pack()); got != e {
+			t.Errorf("unpackEntry(pack(%+v)): got %+v", e, got)
+		}
+	}
+}
+
+func TestTransTableConcurrent(t *testing.T) {
+	tt := NewTransTable(1)
+	// Each goroutine stores entries whose contents are determined by their keys,
+	// so that an entry combining the stores of different goroutines can be detected.
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < 20000; i++ {
+				z := Zobrist(r.Uint64())
+				tt.store(z, Move{}, Rel{n: int(z % 1000)}, exactBound, int(z%64))
+				z = Zobrist(r.Uint64())
+				if e, ok := tt.probe(z); ok && (e.score.Rel() != Rel{n: int(z % 1000)} || int(e.depth) != int(z%64)) {
+					t.Errorf("probe(%v): got inconsistent entry %+v", z, e)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestTransTableStore(t *testing.T) {
+	tt := NewTransTable(1)
+	m := Move{From: e2, To: e4, Piece: Pawn}
+	z := Zobrist(0x1234)
+	if _, ok := tt.probe(z); ok {
+		t.Fatalf("probe(%v) in empty table: got ok", z)
+	}
+
+	tt.store(z, m, Rel{n: 25}, exactBound, 4)
+	if e, ok := tt.probe(z); !ok || e.move != m || e.score.Rel() != (Rel{n: 25}) || e.depth != 4 || e.bound != exactBound {
+		t.Errorf("probe(%v): got %+v, %v", z, e, ok)
+	}
+
+	// A shallower result from the same search does not replace a deeper one.
+	tt.store(z, Move{}, Rel{n: 10}, upperBound, 2)
+	if e, _ := tt.probe(z); e.depth != 4 {
+		t.Errorf("after shallower store: got depth %v, want 4", e.depth)
+	}
+
+	// A result from a later search does, but retains the best move if the new result has none.
+	tt.NewSearch()
+	tt.store(z, Move{}, Rel{n: 10}, upperBound, 2)
+	if e, _ := tt.probe(z); e.depth != 2 || e.move != m || e.bound != upperBound {
+		t.Errorf("after new search: got %+v, want depth 2, move %v, upper bound", e, m)
+	}
+}
+
+func TestTransTableReplace(t *testing.T) {
+	tt := NewTransTable(1)
+	stride := tt.mask + 1
+	// Fill one bucket, then store another position that maps to it.
+	for i := 0; i < ttBucketSize; i++ {
+		tt.store(Zobrist(i)*stride, Move{}, Rel{}, exactBound, 10-i)
+	}
+	z := Zobrist(ttBucketSize) * stride
+	tt.store(z, Move{}, Rel{}, exactBound, 1)
+	if _, ok := tt.probe(z); !ok {
+		t.Errorf("probe(%v): not found after store", z)
+	}
+	shallowest := Zobrist(ttBucketSize-1) * stride
+	if _, ok := tt.probe(shallowest); ok {
+		t.Errorf("probe(%v): shallowest entry not replaced", shallowest)
+	}
+	for i := 0; i < ttBucketSize-1; i++ {
+		if _, ok := tt.probe(Zobrist(i) * stride); !ok {
+			t.Errorf("probe(%v): deeper entry replaced", Zobrist(i)*stride)
+		}
+	}
+}
+
+func TestSearchThreads(t *testing.T) {
+	defer func(tt *TransTable, n int) { hashTable, searchThreads = tt, n }(hashTable, searchThreads)
+	hashTable, searchThreads = NewTransTable(1), 4
+
+	pos, err := ParseFEN("6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: 3}); rs[0].move != (Move{From: a1, To: a8, Piece: Rook}) || rs[0].score.err != checkmateError(1) {
+		t.Errorf("got %v, want Ra1-a8 mate in 1", rs[0])
+	}
+
+	// Canceling the search stops all of the threads.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if rs := SearchPosition(ctx, InitialPosition, nil, SearchOptions{Depth: 100}); len(rs) == 0 {
+		t.Errorf("got no results")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("search took %v after cancellation at 50ms", elapsed)
+	}
+}
+
+func TestSearchTransTable(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	// At greater depths, entries stored by deeper searches of transposed positions
+	// may legitimately change the score.
+	for _, test := range []struct {
+		fen   string
+		depth int
+	}{
+		{"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", 3},
+		{"r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3", 3},
+		{"8/8/8/8/8/2k5/8/KQ6 w - - 0 1", 3},
+	} {
+		pos, err := ParseFEN(test.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashTable = nil
+		want := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: test.depth})[0].score
+		hashTable = NewTransTable(1)
+		if got := SearchPosition(context.Background(), pos, nil, SearchOptions{Depth: test.depth})[0].score; got != want {
+			t.Errorf("SearchPosition(%v, %v) with transposition table: got %v, want %v", test.fen, test.depth, got, want)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uciMaxDepth is the search depth used when the go command does not specify one.
+const uciMaxDepth = 100
+
+// uciEngine holds the state of a Universal Chess Interface session.
+type uciEngine struct {
+	mu sync.Mutex // guards w
+	w  io.Writer
+
+	// pos is the Position set by the most recent position command,
+	// and history holds the Zobrist keys of the positions that preceded it.
+	pos     Position
+	history []Zobrist
+
+	// chess960 reports whether the UCI_Chess960 option is set.
+	chess960 bool
+
+	// cancel stops the running search, if any.
+	cancel context.CancelFunc
+
+	// done is closed when the running search, if any, has reported its best move.
+	done chan struct{}
+
+	// ponderhit, if not nil, starts the clock of the running search, which is pondering.
+	ponderhit func()
+}
+
+// uciMaxHashSize is the maximum size of the transposition table in megabytes.
+const uciMaxHashSize = 1 << 16
+
+// uciMaxMultiPV is the maximum value of the MultiPV option.
+const uciMaxMultiPV = maxMoves
+
+// uciMaxThreads is the maximum value of the Threads option.
+const uciMaxThreads = 512
+
+// uciSearchOptions holds the check options that enable each selective search technique, by lowercase name.
+var uciSearchOptions = map[string]*bool{
+	"pvs":             &principalVariationSearch,
+	"nullmove":        &nullMovePruning,
+	"lmr":             &lateMoveReductions,
+	"checkextensions": &checkExtensions,
+}
+
+// uciLimits describes the parameters of a go command.
+type uciLimits struct {
+	depth    int
+	nodes    int
+	mate     int
+	moveTime time.Duration
+	infinite bool
+
+	// ponder reports whether to search on the opponent's time until the ponderhit command starts the clock.
+	ponder bool
+
+	// time and inc are the remaining clock time and increment of each Color.
+	time, inc [2]time.Duration
+	movesToGo int
+}
+
+// UCI communicates via the Universal Chess Interface protocol.
+// It reads commands from r and writes responses to w until it reads the quit command or reaches the end of r.
+// Commands are read while a search is running, so that the stop command can interrupt it.
+func UCI(r io.Reader, w io.Writer) error {
+	u := &uciEngine{w: w, pos: InitialPosition}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			u.printf("id name bandit\n")
+			u.printf("id author Dan McCandless\n")
+			u.printf("option name Hash type spin default %d min 1 max %d\n", DefaultHashSize, uciMaxHashSize)
+			u.printf("option name Threads type spin default 1 min 1 max %d\n", uciMaxThreads)
+			u.printf("option name MultiPV type spin default 1 min 1 max %d\n", uciMaxMultiPV)
+			u.printf("option name Ponder type check default false\n")
+			u.printf("option name UCI_Chess960 type check default false\n")
+			u.printf("option name SyzygyPath type string default <empty>\n")
+			u.printf("option name PVS type check default %v\n", principalVariationSearch)
+			u.printf("option name NullMove type check default %v\n", nullMovePruning)
+			u.printf("option name LMR type check default %v\n", lateMoveReductions)
+			u.printf("option name CheckExtensions type check default %v\n", checkExtensions)
+			u.printf("uciok\n")
+		case "isready":
+			u.printf("readyok\n")
+		case "ucinewgame":
+			u.stop()
+			u.pos, u.history = InitialPosition, nil
+			if hashTable != nil {
+				hashTable.Clear()
+			}
+		case "setoption":
+			u.stop()
+			if err := u.setOption(fields[1:]); err != nil {
+				u.printf("info string %v\n", err)
+			}
+		case "position":
+			u.stop()
+			pos, history, err := parseUCIPosition(fields[1:], u.chess960)
+			if err != nil {
+				u.printf("info string %v\n", err)
+				continue
+			}
+			u.pos, u.history = pos, history
+		case "go":
+			u.stop()
+			l, err := parseUCILimits(fields[1:])
+			if err != nil {
+				u.printf("info string %v\n", err)
+				continue
+			}
+			u.start(l)
+		case "ponderhit":
+			if u.ponderhit != nil {
+				u.ponderhit()
+				u.ponderhit = nil
+			}
+		case "stop":
+			u.stop()
+		case "quit":
+			u.stop()
+			return nil
+		default:
+			u.printf("info string unknown command %v\n", fields[0])
+		}
+	}
+	u.stop()
+	return s.Err()
+}
+
+// printf writes to u's output.
+func (u *uciEngine) printf(format string, a ...interface{}) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	fmt.Fprintf(u.w, format, a...)
+}
+
+// start begins searching u.pos in the background according to l.
+// If l.ponder is set, the clock is not started until ponderhit is called.
+func (u *uciEngine) start(l uciLimits) {
+	stopCtx, stop := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(stopCtx)
+	clock := newSearchClock(cancel)
+	tm := l.timeManager(u.pos.ToMove)
+	hit := make(chan struct{})
+	if l.ponder {
+		u.ponderhit = func() {
+			clock.start(tm)
+			close(hit)
+		}
+	} else {
+		clock.start(tm)
+		close(hit)
+	}
+	u.cancel = stop
+	u.done = make(chan struct{})
+
+	go func(pos Position, history []Zobrist, done chan struct{}) {
+		defer close(done)
+		defer cancel()
+		defer clock.stop()
+		// completed holds the info lines of the last completed iteration, whose Results the search returns if interrupted,
+		// and bounded reports whether the lines of a search that failed outside of its aspiration window followed them.
+		var completed []string
+		var bounded bool
+		rs := SearchPosition(ctx, pos, history, SearchOptions{Depth: l.depth, Nodes: l.nodes, Mate: l.mate, OnIteration: func(info Info) {
+			n := multiPV
+			if n > len(info.Results) {
+				n = len(info.Results)
+			}
+			var lines []string
+			for i, r := range info.Results[:n] {
+				line := uciInfo(pos, info, r)
+				if n > 1 {
+					line = fmt.Sprintf("multipv %d %v", i+1, line)
+				}
+				u.printf("info %v\n", line)
+				lines = append(lines, line)
+			}
+			if info.Bound != exactBound {
+				// The iteration will be searched again.
+				bounded = true
+				return
+			}
+			completed, bounded = lines, false
+			if clock.iterationDone(info.Best.move, info.Best.score.Rel(pos.ToMove)) {
+				cancel()
+			}
+		}})
+		switch {
+		case l.infinite:
+			// Do not report a best move until instructed to stop.
+			<-stopCtx.Done()
+		case l.ponder:
+			// Nor while pondering.
+			select {
+			case <-stopCtx.Done():
+			case <-hit:
+			}
+		}
+		if len(rs) == 0 {
+			u.printf("bestmove 0000\n")
+			return
+		}
+		if bounded {
+			// Report again the principal variation of the best move, which comes from the last completed iteration.
+			for _, line := range completed {
+				u.printf("info %v\n", line)
+			}
+		}
+		if pv := rs[0].pvMoves(); len(pv) > 1 {
+			u.printf("bestmove %v ponder %v\n", Coordinate(pv[0]), Coordinate(pv[1]))
+			return
+		}
+		u.printf("bestmove %v\n", Coordinate(rs[0].move))
+	}(u.pos, u.history, u.done)
+}
+
+// stop interrupts the running search, if any, and waits for it to report its best move.
+func (u *uciEngine) stop() {
+	if u.cancel == nil {
+		return
+	}
+	u.cancel()
+	<-u.done
+	u.cancel, u.done, u.ponderhit = nil, nil, nil
+}
+
+// uciInfo returns the information reported about the result r of a search of pos with progress info.
+func uciInfo(pos Position, info Info, r Result) string {
+	var pv []string
+	for _, m := range r.pvMoves() {
+		pv = append(pv, Coordinate(m))
+	}
+	var hashfull string
+	if hashTable != nil {
+		hashfull = fmt.Sprintf(" hashfull %d", info.Hashfull)
+	}
+	var tbhits string
+	if tablebases != nil || dtmTables != nil {
+		tbhits = fmt.Sprintf(" tbhits %d", info.TBHits)
+	}
+	var b string
+	switch info.Bound {
+	case lowerBound:
+		b = " lowerbound"
+	case upperBound:
+		b = " upperbound"
+	}
+	return fmt.Sprintf("depth %d seldepth %d score %v%v nodes %d nps %d%v%v time %d pv %v",
+		info.Depth, info.SelDepth, uciScore(r.score.Rel(pos.ToMove)), b, info.Nodes, info.NPS, hashfull, tbhits, info.Elapsed.Milliseconds(), strings.Join(pv, " "))
+}
+
+// uciScore returns the representation of s in the info command, either in centipawns or in moves until checkmate.
+func uciScore(s Rel) string {
+	if n, ok := s.err.(checkmateError); ok {
+		if n&1 != 0 {
+			return fmt.Sprintf("mate %d", (n+1)/2)
+		}
+		return fmt.Sprintf("mate -%d", n/2)
+	}
+	return fmt.Sprintf("cp %d", s.n)
+}
+
+// parseUCIPosition parses the arguments of a position command and returns the described Position
+// and the Zobrist keys of the positions that preceded it. If chess960 is set, the Position is
+// treated as a Chess960 position, in which the king castles by moving to its rook's square.
+func parseUCIPosition(args []string, chess960 bool) (Position, []Zobrist, error) {
+	var pos Position
+	var history []Zobrist
+	var moves []string
+	for i, a := range args {
+		if a == "moves" {
+			args, moves = args[:i], args[i+1:]
+			break
+		}
+	}
+	if len(args) == 0 {
+		return pos, nil, fmt.Errorf("position: missing argument")
+	}
+	switch args[0] {
+	case "startpos":
+		pos = InitialPosition
+	case "fen":
+		var err error
+		if pos, err = ParseFEN(strings.Join(args[1:], " ")); err != nil {
+			return pos, nil, err
+		}
+	default:
+		return pos, nil, fmt.Errorf("position: invalid argument %v", args[0])
+	}
+	pos.Chess960 = pos.Chess960 || chess960
+	for _, s := range moves {
+		m, err := ParseCoordinate(pos, s)
+		if err != nil {
+			return pos, nil, fmt.Errorf("position: move %v: %v", s, err)
+		}
+		history = append(history, pos.z)
+		pos = Make(pos, m)
+	}
+	return pos, history, nil
+}
+
+// setOption parses the arguments of a setoption command and applies the option.
+// The name and value, which may contain spaces, are delimited by the name and value tokens; the value may be omitted.
+func (u *uciEngine) setOption(args []string) error {
+	if len(args) < 2 || args[0] != "name" || args[1] == "value" {
+		return fmt.Errorf("setoption: invalid arguments %v", strings.Join(args, " "))
+	}
+	name, value := strings.Join(args[1:], " "), ""
+	for i, arg := range args[2:] {
+		if arg == "value" {
+			name, value = strings.Join(args[1:i+2], " "), strings.Join(args[i+3:], " ")
+			break
+		}
+	}
+	switch strings.ToLower(name) {
+	case "hash":
+		mb, err := strconv.Atoi(value)
+		if err != nil || mb < 1 || mb > uciMaxHashSize {
+			return fmt.Errorf("setoption: invalid Hash value %v", value)
+		}
+		hashTable = NewTransTable(mb)
+	case "threads":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > uciMaxThreads {
+			return fmt.Errorf("setoption: invalid Threads value %v", value)
+		}
+		searchThreads = n
+	case "multipv":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > uciMaxMultiPV {
+			return fmt.Errorf("setoption: invalid MultiPV value %v", value)
+		}
+		multiPV = n
+	case "uci_chess960":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("setoption: invalid UCI_Chess960 value %v", value)
+		}
+		u.chess960 = b
+	case "syzygypath":
+		if value == "" || value == "<empty>" {
+			tablebases = nil
+			break
+		}
+		tb, err := OpenSyzygy(value)
+		if err != nil {
+			return fmt.Errorf("setoption: %v", err)
+		}
+		tablebases = tb
+	case "ponder":
+		// The option only tells the GUI that it may send go ponder.
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("setoption: invalid Ponder value %v", value)
+		}
+	case "pvs", "nullmove", "lmr", "checkextensions":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("setoption: invalid %v value %v", name, value)
+		}
+		*uciSearchOptions[strings.ToLower(name)] = b
+	default:
+		return fmt.Errorf("setoption: unknown option %v", name)
+	}
+	return nil
+}
+
+// parseUCILimits parses the arguments of a go command.
+func parseUCILimits(args []string) (uciLimits, error) {
+	l := uciLimits{depth: uciMaxDepth}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "infinite":
+			l.infinite = true
+			continue
+		case "ponder":
+			l.ponder = true
+			continue
+		}
+		if i+1 == len(args) {
+			return l, fmt.Errorf("go: missing value for %v", args[i])
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return l, fmt.Errorf("go: invalid value for %v: %v", args[i], err)
+		}
+		ms := time.Duration(n) * time.Millisecond
+		switch args[i] {
+		case "depth":
+			l.depth = n
+		case "nodes":
+			l.nodes = n
+		case "mate":
+			l.mate = n
+		case "movetime":
+			l.moveTime = ms
+		case "wtime":
+			l.time[White] = ms
+		case "btime":
+			l.time[Black] = ms
+		case "winc":
+			l.inc[White] = ms
+		case "binc":
+			l.inc[Black] = ms
+		case "movestogo":
+			l.movesToGo = n
+		default:
+			return l, fmt.Errorf("go: unknown argument %v", args[i])
+		}
+		i++
+	}
+	return l, nil
+}
+
+// timeManager returns the timeManager for a search for c under l.
+func (l uciLimits) timeManager(c Color) timeManager {
+	switch {
+	case l.infinite:
+		return timeManager{}
+	case l.moveTime > 0:
+		return fixedTime(l.moveTime)
+	case l.time[c] > 0:
+		return newTimeManager(l.time[c], l.inc[c], l.movesToGo)
+	default:
+		return timeManager{}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUCIPosition(t *testing.T) {
+	for _, test := range []struct {
+		args     string
+		chess960 bool
+		fen      string
+		history  int
+	}{
+		{"startpos", false, InitialPositionFEN, 0},
+		{"startpos moves", false, InitialPositionFEN, 0},
+		{"startpos moves e2e4 c7c5 g1f3", false, "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2", 3},
+		{"startpos moves e2e4 a7a6 e4e5 d7d5 e5d6", false, "rnbqkbnr/1pp1pppp/p2P4/8/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3", 5},
+		{"fen r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1 moves e1c1 e8g8", false, "r4rk1/8/8/8/8/8/8/2KR3R w - - 2 2", 2},
+		{"fen r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1 moves e1a1 e8h8", true, "r4rk1/8/8/8/8/8/8/2KR3R w - - 2 2", 2},
+		{"fen 1r2k2r/8/8/8/8/8/8/1R2K2R w HBhb - 0 1 moves e1h1 e8b8", false, "2kr3r/8/8/8/8/8/8/1R3RK1 w - - 2 2", 2},
+		{"fen 4k3/1P6/8/8/8/8/8/4K3 w - - 0 1 moves b7b8n", false, "1N2k3/8/8/8/8/8/8/4K3 b - - 0 1", 1},
+	} {
+		pos, history, err := parseUCIPosition(strings.Fields(test.args), test.chess960)
+		if err != nil {
+			t.Errorf("parseUCIPosition(%v): got error %v", test.args, err)
+			continue
+		}
+		if got := FEN(pos); got != test.fen || len(history) != test.history {
+			t.Errorf("parseUCIPosition(%v): got %v with %v history keys, want %v with %v", test.args, got, len(history), test.fen, test.history)
+		}
+	}
+}
+
+func TestParseUCIPositionInvalid(t *testing.T) {
+	for _, test := range []string{
+		"",
+		"moves e2e4",
+		"start",
+		"fen 8/8/8/8 w - - 0 1",
+		"startpos moves e2e5",
+		"startpos moves e2e4 e2e4",
+	} {
+		if pos, _, err := parseUCIPosition(strings.Fields(test), false); err == nil {
+			t.Errorf("parseUCIPosition(%v): got %v, nil; want error", test, FEN(pos))
+		}
+	}
+}
+
+func TestParseUCILimits(t *testing.T) {
+	for _, test := range []struct {
+		args string
+		want uciLimits
+	}{
+		{"", uciLimits{depth: uciMaxDepth}},
+		{"infinite", uciLimits{depth: uciMaxDepth, infinite: true}},
+		{"depth 6", uciLimits{depth: 6}},
+		{"nodes 10000", uciLimits{depth: uciMaxDepth, nodes: 10000}},
+		{"mate 3", uciLimits{depth: uciMaxDepth, mate: 3}},
+		{"movetime 1500", uciLimits{depth: uciMaxDepth, moveTime: 1500 * time.Millisecond}},
+		{"wtime 60000 btime 30000 winc 1000 binc 2000 movestogo 20", uciLimits{
+			depth:     uciMaxDepth,
+			time:      [2]time.Duration{time.Minute, 30 * time.Second},
+			inc:       [2]time.Duration{time.Second, 2 * time.Second},
+			movesToGo: 20,
+		}},
+	} {
+		got, err := parseUCILimits(strings.Fields(test.args))
+		if err != nil || got != test.want {
+			t.Errorf("parseUCILimits(%v): got %+v, %v; want %+v, nil", test.args, got, err, test.want)
+		}
+	}
+	for _, test := range []string{"depth", "depth x", "movetime 100 wtime", "ponies 3"} {
+		if got, err := parseUCILimits(strings.Fields(test)); err == nil {
+			t.Errorf("parseUCILimits(%v): got %+v, nil; want error", test, got)
+		}
+	}
+}
+
+func TestUCILimitsTimeManager(t *testing.T) {
+	for _, test := range []struct {
+		l          uciLimits
+		c          Color
+		soft, hard time.Duration
+	}{
+		{uciLimits{}, White, 0, 0},
+		{uciLimits{infinite: true, moveTime: time.Second}, White, 0, 0},
+		{uciLimits{moveTime: time.Second}, Black, 0, time.Second},
+		{uciLimits{time: [2]time.Duration{30 * time.Second, time.Minute}}, White, time.Second, 5 * time.Second},
+		{uciLimits{time: [2]time.Duration{30 * time.Second, time.Minute}}, Black, 2 * time.Second, 10 * time.Second},
+		{uciLimits{time: [2]time.Duration{10 * time.Second}, inc: [2]time.Duration{2 * time.Second}, movesToGo: 5}, White, 3 * time.Second, 3 * time.Second},
+		{uciLimits{time: [2]time.Duration{time.Second}, movesToGo: 1}, White, time.Second - clockOverhead, time.Second - clockOverhead},
+		{uciLimits{time: [2]time.Duration{10 * time.Millisecond}, movesToGo: 1}, White, time.Millisecond, time.Millisecond},
+	} {
+		if tm := test.l.timeManager(test.c); tm.soft != test.soft || tm.hard != test.hard {
+			t.Errorf("timeManager(%+v, %v): got %v, %v; want %v, %v", test.l, test.c, tm.soft, tm.hard, test.soft, test.hard)
+		}
+	}
+}
+
+func TestUCIScore(t *testing.T) {
+	for _, test := range []struct {
+		s    Rel
+		want string
+	}{
+		{Rel{n: 35}, "cp 35"},
+		{Rel{n: -120}, "cp -120"},
+		{Rel{err: errStalemate}, "cp 0"},
+		{materel(1), "mate 1"},
+		{materel(5), "mate 3"},
+		{materel(2), "mate -1"},
+		{materel(6), "mate -3"},
+	} {
+		if got := uciScore(test.s); got != test.want {
+			t.Errorf("uciScore(%v): got %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestUCIInfoBound(t *testing.T) {
+	pos := mustParseFEN("8/8/8/8/8/2k5/8/K1q5 w - - 0 1")
+	r := Result{move: Move{From: a1, To: a2, Piece: King}, score: Abs{n: -950}}
+	for _, test := range []struct {
+		b    bound
+		want string
+	}{
+		{exactBound, "score cp -950 nodes"},
+		{lowerBound, "score cp -950 lowerbound nodes"},
+		{upperBound, "score cp -950 upperbound nodes"},
+	} {
+		if got := uciInfo(pos, Info{Depth: 2, Bound: test.b}, r); !strings.Contains(got, test.want) {
+			t.Errorf("bound %v: got %q, want %q", test.b, got, test.want)
+		}
+	}
+}
+
+// uciSession runs UCI in the background and returns functions to send it a command
+// and to read its output until a line with the specified prefix.
+func uciSession(t *testing.T) (send func(string), expect func(prefix string) []string, quit func()) {
+	inr, inw := io.Pipe()
+	outr, outw := io.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- UCI(inr, outw)
+		outw.Close()
+	}()
+	out := bufio.NewScanner(outr)
+	send = func(s string) {
+		if _, err := io.WriteString(inw, s+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect = func(prefix string) []string {
+		var lines []string
+		for out.Scan() {
+			lines = append(lines, out.Text())
+			if strings.HasPrefix(out.Text(), prefix) {
+				return lines
+			}
+		}
+		t.Fatalf("expected %v, got %v", prefix, lines)
+		return nil
+	}
+	quit = func() {
+		send("quit")
+		go io.Copy(io.Discard, outr)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func TestUCI(t *testing.T) {
+	send, expect, quit := uciSession(t)
+	defer quit()
+
+	send("uci")
+	expect("uciok")
+	send("isready")
+	expect("readyok")
+
+	send("ucinewgame")
+	send("position fen 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1")
+	send("go depth 3")
+	lines := expect("bestmove")
+	if got := lines[len(lines)-1]; got != "bestmove a1a8" {
+		t.Errorf("got %v, want bestmove a1a8", got)
+	}
+	if !strings.Contains(lines[len(lines)-2], "score mate 1 ") {
+		t.Errorf("got %v, want mate 1 score", lines[len(lines)-2])
+	}
+
+	send("position fen kbK5/pp6/1P6/8/8/8/8/R7 w - - 0 1")
+	send("go mate 2")
+	lines = expect("bestmove")
+	if got := lines[len(lines)-1]; !strings.HasPrefix(got, "bestmove a1a6 ") {
+		t.Errorf("got %v, want bestmove a1a6", got)
+	}
+	if !strings.HasPrefix(lines[len(lines)-2], "info depth 3 ") || !strings.Contains(lines[len(lines)-2], "score mate 2 ") {
+		t.Errorf("got %v, want mate 2 score at depth 3", lines[len(lines)-2])
+	}
+
+	send("position startpos")
+	send("go nodes 1000")
+	if lines := expect("bestmove"); lines[len(lines)-1] == "bestmove 0000" {
+		t.Errorf("got %v after node limit, want a move", lines[len(lines)-1])
+	}
+
+	defer func(n int) { multiPV = n }(multiPV)
+	send("setoption name MultiPV value 2")
+	send("position startpos")
+	send("go depth 2")
+	lines = expect("bestmove")
+	if got := lines[len(lines)-2]; !strings.HasPrefix(got, "info multipv 2 depth 2 ") {
+		t.Errorf("got %v, want second principal variation", got)
+	}
+	send("setoption name MultiPV value 1")
+
+	send("position startpos moves e2e4")
+	send("go infinite")
+	send("isready")
+	expect("readyok")
+	send("stop")
+	lines = expect("bestmove")
+	if got := lines[len(lines)-1]; got == "bestmove 0000" {
+		t.Errorf("got %v after stop, want a move", got)
+	}
+
+	send("position fen 7k/6Q1/6K1/8/8/8/8/8 b - - 0 1")
+	send("go depth 2")
+	if lines := expect("bestmove"); lines[len(lines)-1] != "bestmove 0000" {
+		t.Errorf("got %v in checkmate, want bestmove 0000", lines[len(lines)-1])
+	}
+}
+
+func TestUCIBestMove(t *testing.T) {
+	send, expect, quit := uciSession(t)
+	defer quit()
+
+	// Some of the limits stop the search while it searches again after failing outside of its aspiration window.
+	for nodes := 1000; nodes <= 20000; nodes += 1000 {
+		send("ucinewgame")
+		send("position startpos")
+		send(fmt.Sprintf("go nodes %d", nodes))
+		lines := expect("bestmove")
+		var pv []string
+		for _, line := range lines {
+			if _, after, ok := strings.Cut(line, " pv "); ok {
+				pv = strings.Fields(after)
+			}
+		}
+		want := "bestmove " + pv[0]
+		if len(pv) > 1 {
+			want += " ponder " + pv[1]
+		}
+		if got := lines[len(lines)-1]; got != want {
+			t.Errorf("go nodes %v: got %v, want %v after pv %v", nodes, got, want, strings.Join(pv, " "))
+		}
+	}
+}
+
+func TestUCIPonder(t *testing.T) {
+	send, expect, quit := uciSession(t)
+	defer quit()
+
+	send("position startpos moves e2e4 e7e5")
+	send("go ponder depth 2 wtime 1000 btime 1000")
+	// Allow the search to finish.
+	time.Sleep(50 * time.Millisecond)
+	send("isready")
+	for _, line := range expect("readyok") {
+		if strings.HasPrefix(line, "bestmove") {
+			t.Errorf("got %v before ponderhit", line)
+		}
+	}
+	send("ponderhit")
+	lines := expect("bestmove")
+	if got := strings.Fields(lines[len(lines)-1]); len(got) != 4 || got[2] != "ponder" {
+		t.Errorf("got %v, want a best move and a move to ponder", lines[len(lines)-1])
+	}
+
+	send("go ponder wtime 1000 btime 1000")
+	send("stop")
+	lines = expect("bestmove")
+	if got := lines[len(lines)-1]; got == "bestmove 0000" {
+		t.Errorf("got %v after stop, want a move", got)
+	}
+}
+
+func TestSetUCIOption(t *testing.T) {
+	defer func(tt *TransTable) { hashTable = tt }(hashTable)
+	u := &uciEngine{}
+	if err := u.setOption(strings.Fields("name Hash value 2")); err != nil || hashTable == nil {
+		t.Errorf("setoption name Hash value 2: got %v, %v", hashTable, err)
+	}
+	defer func(n int) { searchThreads = n }(searchThreads)
+	if err := u.setOption(strings.Fields("name Threads value 4")); err != nil || searchThreads != 4 {
+		t.Errorf("setoption name Threads value 4: got %v, %v", searchThreads, err)
+	}
+	defer func(n int) { multiPV = n }(multiPV)
+	if err := u.setOption(strings.Fields("name MultiPV value 3")); err != nil || multiPV != 3 {
+		t.Errorf("setoption name MultiPV value 3: got %v, %v", multiPV, err)
+	}
+	if err := u.setOption(strings.Fields("name UCI_Chess960 value true")); err != nil || !u.chess960 {
+		t.Errorf("setoption name UCI_Chess960 value true: got %v, %v", u.chess960, err)
+	}
+	defer setSelectivity(setSelectivity(true, true, true, true))
+	if err := u.setOption(strings.Fields("name NullMove value false")); err != nil || nullMovePruning {
+		t.Errorf("setoption name NullMove value false: got %v, %v", nullMovePruning, err)
+	}
+	defer func(tb tablebase) { tablebases = tb }(tablebases)
+	tablebases = materialTablebase(3)
+	if err := u.setOption(strings.Fields("name SyzygyPath value")); err != nil || tablebases != nil {
+		t.Errorf("setoption name SyzygyPath value: got %v, %v", tablebases, err)
+	}
+	dir := filepath.Join(t.TempDir(), "syzygy tables")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	// The path, which contains a space, is passed whole to OpenSyzygy, which finds no tables in it.
+	if err := u.setOption(strings.Fields("name SyzygyPath value " + dir)); err == nil || !strings.HasSuffix(err.Error(), "no Syzygy tables in "+dir) {
+		t.Errorf("setoption name SyzygyPath value %v: got %v, want no tables in %v", dir, err, dir)
+	}
+	if err := u.setOption(strings.Fields("name Check Extensions value true")); err == nil || !strings.Contains(err.Error(), "unknown option Check Extensions") {
+		t.Errorf("setoption name Check Extensions value true: got %v, want unknown option", err)
+	}
+	for _, test := range []string{"", "name", "name value 2", "name Hash", "name Hash value", "name Hash value x", "name Hash value 2 3", "name Hash value 0", "name Ponies value 3", "name MultiPV value 0", "name Threads value 0", "name UCI_Chess960 value maybe", "name LMR value 2"} {
+		if err := u.setOption(strings.Fields(test)); err == nil {
+			t.Errorf("setoption %v: got nil, want error", test)
+		}
+	}
+}
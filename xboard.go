@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xboardMaxDepth is the search depth used when the sd command has not specified one.
+const xboardMaxDepth = 100
+
+// xboardDefaultTime is the time per move used when neither a clock nor a fixed time per move has been set.
+const xboardDefaultTime = 3 * time.Second
+
+// xboardEngine holds the state of an XBoard/CECP (Chess Engine Communication Protocol) session.
+type xboardEngine struct {
+	w io.Writer
+
+	// positions is the game history, beginning with the starting position and ending with the current one.
+	// positions[i+1] is the result of applying moves[i] to positions[i].
+	positions []Position
+	moves     []Move
+
+	// players holds the Player, if any, that moves for each Color.
+	// A nil Player indicates that the moves of that Color are read from input.
+	players [2]Player
+
+	// post reports whether to send thinking output.
+	post bool
+
+	// depth is the maximum search depth.
+	depth int
+
+	// moveTime is the fixed time per move set by the st command, if any.
+	moveTime time.Duration
+
+	// mps, base, and inc are the time control parameters set by the level command:
+	// the number of moves per time control, or 0 for a single control for the whole game,
+	// the time allotted per control, and the increment per move.
+	mps       int
+	base, inc time.Duration
+
+	// clock is the engine's remaining time, as set by the time command.
+	clock time.Duration
+
+	// chess960 reports whether the variant command selected Fischer Random Chess.
+	chess960 bool
+
+	// ponder reports whether to search on the opponent's time, as set by the hard and easy commands.
+	ponder   bool
+	ponderer ponderer
+}
+
+// XBoard communicates via the XBoard/WinBoard Chess Engine Communication Protocol.
+// It reads commands from r and writes responses to w until it reads the quit command or reaches the end of r.
+func XBoard(r io.Reader, w io.Writer) error {
+	x := &xboardEngine{w: w, depth: xboardMaxDepth}
+	x.newGame(InitialPosition)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		args := fields[1:]
+		switch fields[0] {
+		case "usermove", "time", "otim", "ping", "post", "nopost", "hard", "computer", "name", "ics", "accepted", "rejected":
+		default:
+			// The game is not continuing as predicted.
+			x.ponderer.stop()
+		}
+		switch fields[0] {
+		case "xboard", "accepted", "rejected", "random", "computer", "name", "ics", "otim":
+		case "protover":
+			fmt.Fprintln(w, `feature myname="bandit" setboard=1 usermove=1 ping=1 playother=0 san=0 time=1 draw=0 sigint=0 sigterm=0 reuse=1 analyze=0 colors=0 variants="normal,fischerandom" done=1`)
+		case "ping":
+			fmt.Fprintf(w, "pong %v\n", strings.Join(args, " "))
+		case "new":
+			x.newGame(InitialPosition)
+			x.players[Black] = x.computer()
+			x.depth, x.clock = xboardMaxDepth, x.base
+			x.chess960 = false
+		case "variant":
+			if len(args) != 1 || (args[0] != "normal" && args[0] != "fischerandom") {
+				fmt.Fprintf(w, "Error (unsupported variant): %v\n", s.Text())
+				continue
+			}
+			x.chess960 = args[0] == "fischerandom"
+		case "setboard":
+			pos, err := ParseFEN(strings.Join(args, " "))
+			if err != nil {
+				fmt.Fprintf(w, "tellusererror Illegal position: %v\n", err)
+				continue
+			}
+			pos.Chess960 = pos.Chess960 || x.chess960
+			x.newGame(pos)
+		case "force", "result":
+			x.players = [2]Player{}
+		case "go":
+			x.players = [2]Player{}
+			x.players[x.pos().ToMove] = x.computer()
+			x.play()
+		case "usermove":
+			if len(args) != 1 {
+				fmt.Fprintf(w, "Error (missing move): %v\n", fields[0])
+				continue
+			}
+			m, err := x.parseMove(args[0])
+			if err != nil {
+				fmt.Fprintf(w, "Illegal move (%v): %v\n", err, args[0])
+				continue
+			}
+			x.makeMove(m)
+			x.play()
+		case "undo":
+			x.undo(1)
+		case "remove":
+			x.undo(2)
+		case "hard":
+			x.ponder = true
+		case "easy":
+			x.ponder = false
+		case "post":
+			x.post = true
+		case "nopost":
+			x.post = false
+		case "level":
+			if err := x.level(args); err != nil {
+				fmt.Fprintf(w, "Error (%v): %v\n", err, s.Text())
+			}
+		case "st", "sd", "time":
+			if len(args) != 1 {
+				fmt.Fprintf(w, "Error (missing argument): %v\n", fields[0])
+				continue
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(w, "Error (%v): %v\n", err, s.Text())
+				continue
+			}
+			switch fields[0] {
+			case "st":
+				x.moveTime = time.Duration(n) * time.Second
+			case "sd":
+				x.depth = n
+			case "time":
+				x.clock = time.Duration(n) * 10 * time.Millisecond
+			}
+		case "quit":
+			return nil
+		default:
+			fmt.Fprintf(w, "Error (unknown command): %v\n", fields[0])
+		}
+	}
+	x.ponderer.stop()
+	return s.Err()
+}
+
+// pos returns the current Position.
+func (x *xboardEngine) pos() Position { return x.positions[len(x.positions)-1] }
+
+// history returns the Zobrist keys of the positions that preceded the current Position.
+func (x *xboardEngine) history() []Zobrist {
+	history := make([]Zobrist, len(x.moves))
+	for i := range history {
+		history[i] = x.positions[i].z
+	}
+	return history
+}
+
+// newGame resets the game history to begin at pos and sets the engine to play neither side.
+func (x *xboardEngine) newGame(pos Position) {
+	x.positions = []Position{pos}
+	x.moves = nil
+	x.players = [2]Player{}
+}
+
+// makeMove applies m to the current Position.
+func (x *xboardEngine) makeMove(m Move) {
+	x.positions = append(x.positions, Make(x.pos(), m))
+	x.moves = append(x.moves, m)
+}
+
+// parseMove parses s as a Move in the current Position in coordinate notation.
+// In Chess960, castling moves are given as O-O and O-O-O.
+func (x *xboardEngine) parseMove(s string) (Move, error) {
+	switch s {
+	case "O-O", "O-O-O":
+		return ParseAlgebraic(x.pos(), s)
+	}
+	return ParseCoordinate(x.pos(), s)
+}
+
+// formatXBoardMove returns the coordinate notation of m, or O-O or O-O-O for a Chess960 castling move.
+func formatXBoardMove(m Move) string {
+	if side, ok := m.IsCastle(); ok && m.Castle960 {
+		if side == QS {
+			return "O-O-O"
+		}
+		return "O-O"
+	}
+	return Coordinate(m)
+}
+
+// undo retracts the specified number of moves, if they have been played.
+func (x *xboardEngine) undo(n int) {
+	if n > len(x.moves) {
+		n = len(x.moves)
+	}
+	x.positions = x.positions[:len(x.positions)-n]
+	x.moves = x.moves[:len(x.moves)-n]
+}
+
+// level parses the arguments of a level command: the number of moves per time control,
+// the base time in minutes or minutes:seconds, and the increment in seconds.
+func (x *xboardEngine) level(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("%v arguments (need 3)", len(args))
+	}
+	mps, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	var base time.Duration
+	min, sec := args[1], "0"
+	if i := strings.Index(min, ":"); i != -1 {
+		min, sec = min[:i], min[i+1:]
+	}
+	for _, f := range []struct {
+		s    string
+		unit time.Duration
+	}{{min, time.Minute}, {sec, time.Second}} {
+		n, err := strconv.Atoi(f.s)
+		if err != nil {
+			return err
+		}
+		base += time.Duration(n) * f.unit
+	}
+	inc, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		return err
+	}
+	x.mps, x.base, x.inc = mps, base, time.Duration(inc*float64(time.Second))
+	x.clock, x.moveTime = base, 0
+	return nil
+}
+
+// computer returns a Computer configured with the current search limits.
+func (x *xboardEngine) computer() Computer {
+	c := Computer{tm: x.timeManager(), depth: x.depth}
+	c.onIteration = func(info Info) {
+		// Thinking output has no notation for a bound, so searches that fail outside their aspiration window are omitted.
+		if x.post && info.Bound == exactBound {
+			fmt.Fprintln(x.w, xboardThinking(x.pos(), info))
+		}
+	}
+	if x.ponder {
+		c.ponder = &x.ponderer
+	}
+	return c
+}
+
+// timeManager returns the timeManager for the engine's next move.
+func (x *xboardEngine) timeManager() timeManager {
+	if x.moveTime > 0 {
+		return fixedTime(x.moveTime)
+	}
+	if x.clock <= 0 {
+		return fixedTime(xboardDefaultTime)
+	}
+	var movesToGo int
+	if x.mps > 0 {
+		// Count the moves that the side to move has made in this game.
+		movesToGo = x.mps - len(x.moves)/2%x.mps
+	}
+	return newTimeManager(x.clock, x.inc, movesToGo)
+}
+
+// play makes moves for the engine as long as it is assigned the side to move and the game is not over,
+// and reports the result if the game ends.
+func (x *xboardEngine) play() {
+	for {
+		if result := x.result(); result != "" {
+			x.ponderer.stop()
+			fmt.Fprintln(x.w, result)
+			x.players = [2]Player{}
+			return
+		}
+		p := x.players[x.pos().ToMove]
+		if p == nil {
+			return
+		}
+		if _, ok := p.(Computer); ok {
+			// Refresh the time allotment and the start time of the thinking output.
+			p = x.computer()
+		}
+		_, m := p.Play(x.pos(), x.history())
+		if m == (Move{}) {
+			fmt.Fprintln(x.w, "resign")
+			x.players = [2]Player{}
+			return
+		}
+		x.makeMove(m)
+		fmt.Fprintf(x.w, "move %v\n", formatXBoardMove(m))
+	}
+}
+
+// result returns the result of the game in the format of the RESULT command, or "" if the game is not over.
+func (x *xboardEngine) result() string {
+	pos := x.pos()
+	switch checkTerminal(pos) {
+	case errCheckmate:
+		if pos.ToMove == White {
+			return "0-1 {Black mates}"
+		}
+		return "1-0 {White mates}"
+	case errStalemate:
+		return "1/2-1/2 {Stalemate}"
+	case errFiftyMove:
+		return "1/2-1/2 {Fifty move rule}"
+	}
+	if IsInsufficient(pos) {
+		return "1/2-1/2 {Insufficient material}"
+	}
+	var n int
+	for _, p := range x.positions {
+		if p.z == pos.z {
+			n++
+		}
+	}
+	if n >= 3 {
+		return "1/2-1/2 {Draw by repetition}"
+	}
+	return ""
+}
+
+// xboardThinking returns a line of thinking output describing the progress of a search of pos:
+// the depth, the score in centipawns relative to the side to move, the elapsed time in centiseconds,
+// the number of nodes searched, and the principal variation.
+func xboardThinking(pos Position, info Info) string {
+	return fmt.Sprintf("%d %d %d %d %v", info.Depth, xboardScore(info.Best.score.Rel(pos.ToMove)), info.Elapsed.Milliseconds()/10, info.Nodes, info.Best.PV())
+}
+
+// xboardScore returns the representation of s in thinking output.
+// Checkmate scores are reported as 100000+n for a win in n moves and -100000-n for a loss in n moves.
+func xboardScore(s Rel) int {
+	if n, ok := s.err.(checkmateError); ok {
+		if n&1 != 0 {
+			return 100000 + int(n+1)/2
+		}
+		return -100000 - int(n)/2
+	}
+	return s.n
+}
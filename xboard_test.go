@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xboardOutput runs XBoard with the given input lines and returns its output lines.
+func xboardOutput(t *testing.T, input ...string) []string {
+	var b bytes.Buffer
+	if err := XBoard(strings.NewReader(strings.Join(input, "\n")), &b); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(b.String()), "\n")
+}
+
+func TestXBoardMate(t *testing.T) {
+	got := xboardOutput(t,
+		"xboard",
+		"new",
+		"setboard 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+		"sd 3",
+		"post",
+		"go",
+	)
+	if len(got) < 3 {
+		t.Fatalf("got %q, want thinking output, move, and result", got)
+	}
+	if thinking := got[len(got)-3]; !strings.HasPrefix(thinking, "3 100001 ") || !strings.HasSuffix(thinking, " Ra1-a8") {
+		t.Errorf("got thinking output %q, want depth 3 mate in 1", thinking)
+	}
+	if want := []string{"move a1a8", "1-0 {White mates}"}; got[len(got)-2] != want[0] || got[len(got)-1] != want[1] {
+		t.Errorf("got %q, want %q", got[len(got)-2:], want)
+	}
+}
+
+func TestXBoardForceUndo(t *testing.T) {
+	got := xboardOutput(t,
+		"new",
+		"force",
+		"usermove e2e4",
+		"usermove e7e5",
+		"undo",
+		"usermove c7c5",
+		"remove",
+		"usermove d2d4",
+		"usermove e2e4",
+		"ping 1",
+	)
+	if want := []string{"Illegal move (White piece on square e2): e2e4", "pong 1"}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestXBoardChess960(t *testing.T) {
+	got := xboardOutput(t,
+		"new",
+		"variant fischerandom",
+		"setboard 1r2k2r/8/8/8/8/8/8/1R2K2R w KQkq - 0 1",
+		"force",
+		"usermove O-O",
+		"usermove e8b8",
+		"usermove g1h1",
+		"ping 1",
+	)
+	if want := []string{"Illegal move (illegal move): g1h1", "pong 1"}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := formatXBoardMove(Move{From: e1, To: b1, Piece: King, Castle960: true}); got != "O-O-O" {
+		t.Errorf("got %v, want O-O-O", got)
+	}
+}
+
+func TestXBoardUserMove(t *testing.T) {
+	got := xboardOutput(t,
+		"new",
+		"setboard 4k3/8/8/8/8/8/3p4/3K4 b - - 0 1",
+		"force",
+		"usermove d2d1q",
+		"new",
+		"sd 1",
+		"nopost",
+		"usermove e2e4",
+	)
+	if len(got) != 2 || got[0] != "Illegal move (illegal move): d2d1q" || !strings.HasPrefix(got[1], "move ") {
+		t.Errorf("got %q, want illegal move and engine move", got)
+	}
+}
+
+func TestXBoardLevel(t *testing.T) {
+	for _, test := range []struct {
+		args      string
+		mps       int
+		base, inc time.Duration
+	}{
+		{"40 5 0", 40, 5 * time.Minute, 0},
+		{"0 2 12", 0, 2 * time.Minute, 12 * time.Second},
+		{"0 0:30 0.5", 0, 30 * time.Second, 500 * time.Millisecond},
+	} {
+		x := new(xboardEngine)
+		if err := x.level(strings.Fields(test.args)); err != nil || x.mps != test.mps || x.base != test.base || x.inc != test.inc || x.clock != test.base {
+			t.Errorf("level(%v): got %v, %v, %v, %v; want %v, %v, %v, nil", test.args, x.mps, x.base, x.inc, err, test.mps, test.base, test.inc)
+		}
+	}
+	for _, test := range []string{"40 5", "x 5 0", "40 a 0", "40 5:b 0", "40 5 c"} {
+		if err := new(xboardEngine).level(strings.Fields(test)); err == nil {
+			t.Errorf("level(%v): got nil, want error", test)
+		}
+	}
+}
+
+func TestXBoardTimeManager(t *testing.T) {
+	for _, test := range []struct {
+		x          xboardEngine
+		soft, hard time.Duration
+	}{
+		{xboardEngine{}, 0, xboardDefaultTime},
+		{xboardEngine{moveTime: 5 * time.Second, clock: time.Minute}, 0, 5 * time.Second},
+		{xboardEngine{clock: 3 * time.Minute}, 6 * time.Second, 30 * time.Second},
+		{xboardEngine{clock: 3 * time.Minute, inc: 2 * time.Second}, 7 * time.Second, 35 * time.Second},
+		{xboardEngine{clock: 20 * time.Second, mps: 40, moves: make([]Move, 60)}, 2 * time.Second, (20*time.Second - clockOverhead) / 4},
+		{xboardEngine{clock: 20 * time.Second, mps: 40, moves: make([]Move, 79)}, 20*time.Second - clockOverhead, 20*time.Second - clockOverhead},
+	} {
+		if tm := test.x.timeManager(); tm.soft != test.soft || tm.hard != test.hard {
+			t.Errorf("timeManager(%v moves, %v, %v, %v, %v): got %v, %v; want %v, %v", len(test.x.moves), test.x.moveTime, test.x.mps, test.x.clock, test.x.inc, tm.soft, tm.hard, test.soft, test.hard)
+		}
+	}
+}
+
+func TestXBoardScore(t *testing.T) {
+	for _, test := range []struct {
+		s    Rel
+		want int
+	}{
+		{Rel{n: 35}, 35},
+		{Rel{n: -120}, -120},
+		{Rel{err: errInsufficient}, 0},
+		{materel(1), 100001},
+		{materel(5), 100003},
+		{materel(2), -100001},
+		{materel(6), -100003},
+	} {
+		if got := xboardScore(test.s); got != test.want {
+			t.Errorf("xboardScore(%v): got %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestXBoardPonder(t *testing.T) {
+	got := xboardOutput(t,
+		"new",
+		"hard",
+		"sd 3",
+		"usermove e2e4",
+		"usermove d2d4",
+		"easy",
+		"usermove g1f3",
+		"ping 1",
+	)
+	var moves int
+	for _, line := range got {
+		if strings.HasPrefix(line, "move ") {
+			moves++
+		}
+	}
+	if moves != 3 || got[len(got)-1] != "pong 1" {
+		t.Errorf("got %q, want 3 moves and pong 1", got)
+	}
+}